@@ -0,0 +1,162 @@
+// configcrypt 加密、解密配置中 enc: 前缀的值
+//
+//	configcrypt genkey -id k2
+//	configcrypt encrypt -key-file keys.txt -id k2 'password'
+//	echo 'password' | configcrypt encrypt
+//	configcrypt decrypt 'enc:k2:...'
+//
+// 密钥文件每行一个 id:base64key，未指定 -key-file 时从 CONFIG_SECRET_KEYS 或 CONFIG_SECRET_KEY_FILE 环境变量加载
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/airunny/wiki-go-tools/config"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "genkey":
+		err = genKey(os.Args[2:])
+	case "encrypt":
+		err = encrypt(os.Args[2:])
+	case "decrypt":
+		err = decrypt(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: configcrypt genkey|encrypt|decrypt [-key-file file] [-id key id] [value]")
+}
+
+func genKey(args []string) error {
+	fs := flag.NewFlagSet("genkey", flag.ExitOnError)
+	id := fs.String("id", "k1", "key id")
+	size := fs.Int("size", 32, "key size, 16, 24 or 32")
+	_ = fs.Parse(args)
+
+	key := make([]byte, *size)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+
+	// 校验 id 和长度
+	if err := config.NewKeyring().Add(*id, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s:%s\n", *id, base64.StdEncoding.EncodeToString(key))
+	return nil
+}
+
+func encrypt(args []string) error {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "key file, one id:base64key per line")
+	id := fs.String("id", "", "key id used to encrypt, default is the first key")
+	_ = fs.Parse(args)
+
+	keyring, err := loadKeyring(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	if *id != "" {
+		if err = keyring.SetPrimary(*id); err != nil {
+			return err
+		}
+	}
+
+	value, err := readValue(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	out, err := keyring.Encrypt(value)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(out)
+	return nil
+}
+
+func decrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "key file, one id:base64key per line")
+	_ = fs.Parse(args)
+
+	keyring, err := loadKeyring(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	value, err := readValue(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	out, err := keyring.Decrypt(value)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(out)
+	return nil
+}
+
+func loadKeyring(path string) (*config.Keyring, error) {
+	var (
+		keyring *config.Keyring
+		err     error
+	)
+
+	if path != "" {
+		keyring, err = config.LoadKeyringFile(path)
+	} else {
+		keyring, err = config.LoadKeyringFromEnv()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if keyring == nil || keyring.Len() == 0 {
+		return nil, errors.New("no secret key, use -key-file or set CONFIG_SECRET_KEYS")
+	}
+	return keyring, nil
+}
+
+// readValue reads the value from the arguments or stdin, so that the plaintext needn't be in the shell history
+func readValue(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(value, "\r\n"), nil
+}
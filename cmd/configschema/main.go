@@ -0,0 +1,36 @@
+// configschema 生成 conf.Bootstrap 的 JSON Schema，用于编辑器补全和校验配置文件
+//
+//	configschema -o config.schema.json
+//
+// 服务自定义的配置可以直接调用 config.JSONSchema 生成
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/airunny/wiki-go-tools/config"
+	"github.com/airunny/wiki-go-tools/config/conf"
+)
+
+func main() {
+	output := flag.String("o", "", "output file, default is stdout")
+	flag.Parse()
+
+	data, err := config.JSONSchema(&conf.Bootstrap{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		fmt.Println(string(data))
+		return
+	}
+
+	if err = os.WriteFile(*output, append(data, '\n'), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,358 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+const apolloSourceKey = "apollo"
+
+const (
+	formatYAML       = "yaml"
+	formatYML        = "yml"
+	formatJSON       = "json"
+	formatProperties = "properties"
+)
+
+// namespaceFormat 根据 namespace 后缀推断格式，没有后缀的 namespace（例如 application）为 properties
+func namespaceFormat(namespace string) string {
+	i := strings.LastIndex(namespace, ".")
+	if i < 0 {
+		return formatProperties
+	}
+
+	switch suffix := namespace[i+1:]; suffix {
+	case formatYAML, formatYML, formatJSON:
+		return suffix
+	}
+	return formatProperties
+}
+
+// namespaceName returns the namespace without the format suffix, eg: redis.yaml => redis
+func namespaceName(namespace string) string {
+	i := strings.LastIndex(namespace, ".")
+	if i < 0 {
+		return namespace
+	}
+
+	switch namespace[i+1:] {
+	case formatYAML, formatYML, formatJSON, formatProperties:
+		return namespace[:i]
+	}
+	return namespace
+}
+
+// apolloSource merges several apollo namespaces into one json KeyValue.
+// yaml/json namespaces are read as the original content, properties namespaces are resolved by the
+// apollo source into nested maps under the namespace name, which is removed here.
+type apolloSource struct {
+	source     config.Source
+	namespaces []string
+	prefix     bool
+}
+
+func newApolloSource(source config.Source, namespaces []string, prefix bool) config.Source {
+	return &apolloSource{
+		source:     source,
+		namespaces: namespaces,
+		prefix:     prefix,
+	}
+}
+
+func (s *apolloSource) Load() ([]*config.KeyValue, error) {
+	cache, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return s.merge(cache)
+}
+
+func (s *apolloSource) Watch() (config.Watcher, error) {
+	// 以当前的配置作为初始值，变更时只会返回发生变化的 namespace
+	// apollo 不可用时从空的配置开始，恢复之后由变更事件补全
+	cache, err := s.load()
+	if err != nil {
+		log.Warnf("apollo is unavailable, watch from empty config: %v", err)
+		cache = map[string]map[string]interface{}{}
+	}
+
+	w, err := s.source.Watch()
+	if err != nil {
+		return nil, err
+	}
+
+	return &apolloWatcher{
+		Watcher: w,
+		source:  s,
+		cache:   cache,
+	}, nil
+}
+
+func (s *apolloSource) load() (cache map[string]map[string]interface{}, err error) {
+	// apollo 不可用时 namespace 的缓存可能为 nil，apollo source 会直接 panic
+	defer func() {
+		if r := recover(); r != nil {
+			cache, err = nil, fmt.Errorf("apollo load failed: %v", r)
+		}
+	}()
+
+	kvs, err := s.source.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	cache = make(map[string]map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		values, err := decodeNamespace(kv)
+		if err != nil {
+			return nil, err
+		}
+		cache[kv.Key] = values
+	}
+
+	// apollo source 会忽略加载失败的 namespace
+	for _, namespace := range s.namespaces {
+		if _, ok := cache[namespace]; !ok {
+			return nil, fmt.Errorf("apollo namespace %s is not loaded", namespace)
+		}
+	}
+	return cache, nil
+}
+
+// merge merges the namespaces in the configured order, the same key defined in two namespaces is an error
+func (s *apolloSource) merge(cache map[string]map[string]interface{}) ([]*config.KeyValue, error) {
+	var (
+		merged = map[string]interface{}{}
+		owners = map[string]string{}
+	)
+
+	for _, namespace := range s.orderedNamespaces(cache) {
+		values, ok := cache[namespace]
+		if !ok {
+			continue
+		}
+
+		if s.prefix {
+			segments := strings.Split(namespaceName(namespace), ".")
+			for i := len(segments) - 1; i >= 0; i-- {
+				values = map[string]interface{}{segments[i]: values}
+			}
+		}
+
+		// 复制一份，避免合并时修改缓存中的值
+		if err := mergeNamespace(merged, cloneValues(values), nil, namespace, owners); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*config.KeyValue{
+		{
+			Key:    apolloSourceKey,
+			Value:  data,
+			Format: formatJSON,
+		},
+	}, nil
+}
+
+// orderedNamespaces returns the configured namespaces first, then the others in sorted order
+func (s *apolloSource) orderedNamespaces(cache map[string]map[string]interface{}) []string {
+	var (
+		out  = make([]string, 0, len(cache))
+		seen = make(map[string]struct{}, len(cache))
+	)
+
+	for _, namespace := range s.namespaces {
+		if _, ok := seen[namespace]; ok {
+			continue
+		}
+		seen[namespace] = struct{}{}
+		out = append(out, namespace)
+	}
+
+	var others []string
+	for namespace := range cache {
+		if _, ok := seen[namespace]; !ok {
+			others = append(others, namespace)
+		}
+	}
+	sort.Strings(others)
+	return append(out, others...)
+}
+
+type apolloWatcher struct {
+	config.Watcher
+	source *apolloSource
+
+	mu    sync.Mutex
+	cache map[string]map[string]interface{}
+}
+
+func (w *apolloWatcher) Next() ([]*config.KeyValue, error) {
+	kvs, err := w.Watcher.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next := make(map[string]map[string]interface{}, len(w.cache))
+	for namespace, values := range w.cache {
+		next[namespace] = values
+	}
+
+	for _, kv := range kvs {
+		values, err := decodeNamespace(kv)
+		if err != nil {
+			return nil, err
+		}
+
+		// properties 格式的 namespace 变更时只包含发生变化的 key
+		if namespaceFormat(kv.Key) == formatProperties {
+			values = patchValues(cloneValues(next[kv.Key]), values)
+		}
+		next[kv.Key] = values
+	}
+
+	out, err := w.source.merge(next)
+	if err != nil {
+		return nil, err
+	}
+
+	w.cache = next
+	return out, nil
+}
+
+// decodeNamespace decodes the namespace content with the format of the suffix
+func decodeNamespace(kv *config.KeyValue) (map[string]interface{}, error) {
+	codec, err := codecOf(kv.Format)
+	if err != nil {
+		return nil, fmt.Errorf("apollo namespace %s: %w", kv.Key, err)
+	}
+
+	values := map[string]interface{}{}
+	if err = codec.Unmarshal(kv.Value, &values); err != nil {
+		return nil, fmt.Errorf("apollo namespace %s: %w", kv.Key, err)
+	}
+
+	if namespaceFormat(kv.Key) != formatProperties {
+		return values, nil
+	}
+
+	// properties 的 key 会被加上 namespace 前缀，例如 application.data.redis.address，
+	// 带 . 的 namespace 按 . 嵌套，例如 TEST1.common 的 key 为 TEST1 => common => data
+	name := namespaceName(kv.Key)
+	if len(values) == 0 {
+		return values, nil
+	}
+
+	for _, segment := range strings.Split(name, ".") {
+		sub, ok := values[segment].(map[string]interface{})
+		if !ok || len(values) != 1 {
+			return nil, fmt.Errorf("apollo namespace %s: unexpected properties without the prefix %s", kv.Key, name)
+		}
+		values = sub
+	}
+	return values, nil
+}
+
+// mergeNamespace merges src into dst recursively, owners records which namespace defines the key
+func mergeNamespace(dst, src map[string]interface{}, path []string, namespace string, owners map[string]string) error {
+	for key, value := range src {
+		var (
+			keyPath = append(append([]string{}, path...), key)
+			name    = strings.Join(keyPath, ".")
+		)
+
+		exist, ok := dst[key]
+		if !ok {
+			dst[key] = value
+			markOwner(value, name, namespace, owners)
+			continue
+		}
+
+		existMap, existIsMap := exist.(map[string]interface{})
+		valueMap, valueIsMap := value.(map[string]interface{})
+		if existIsMap && valueIsMap {
+			if err := mergeNamespace(existMap, valueMap, keyPath, namespace, owners); err != nil {
+				return err
+			}
+			continue
+		}
+
+		return fmt.Errorf("apollo key %s is defined in both namespace %s and %s", name, ownerOf(name, owners), namespace)
+	}
+	return nil
+}
+
+func markOwner(value interface{}, name, namespace string, owners map[string]string) {
+	owners[name] = namespace
+	if m, ok := value.(map[string]interface{}); ok {
+		for key, sub := range m {
+			markOwner(sub, name+"."+key, namespace, owners)
+		}
+	}
+}
+
+func ownerOf(name string, owners map[string]string) string {
+	if owner, ok := owners[name]; ok {
+		return owner
+	}
+	return "unknown"
+}
+
+func cloneValues(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		out[key] = cloneValue(value)
+	}
+	return out
+}
+
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return cloneValues(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = cloneValue(item)
+		}
+		return out
+	}
+	return value
+}
+
+// patchValues applies the changed keys of a properties namespace, nil means the key was deleted
+func patchValues(dst, changes map[string]interface{}) map[string]interface{} {
+	for key, value := range changes {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+
+		sub, ok := value.(map[string]interface{})
+		if !ok {
+			dst[key] = value
+			continue
+		}
+
+		exist, ok := dst[key].(map[string]interface{})
+		if !ok {
+			exist = map[string]interface{}{}
+		}
+		dst[key] = patchValues(exist, sub)
+	}
+	return dst
+}
@@ -42,12 +42,14 @@ type ServerConfig struct {
 	OssDomain string             `protobuf:"bytes,4,opt,name=oss_domain,json=ossDomain,proto3" json:"oss_domain"` // 对象存储domain
 }
 
+// Deprecated: use conf.Bootstrap, the business fields belong to the service itself
 type Bootstrap struct {
 	Server   *ServerConfig `json:"server"`
 	Data     *DataConfig   `json:"data"`
 	Business *Business     `json:"business"`
 }
 
+// Deprecated: use conf.Kafka
 type Consumer struct {
 	Brokers      []string `json:"brokers"`
 	Topics       []string `json:"topics"`
@@ -74,6 +76,7 @@ type Elastic struct {
 	Password string `json:"password"`
 }
 
+// Deprecated: the business fields belong to the service itself
 type Business struct {
 	Aws                   *AWS      `json:"aws"`
 	Elastic               *Elastic  `json:"elastic"`
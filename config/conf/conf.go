@@ -0,0 +1,152 @@
+// Package conf 提供各个服务通用的配置结构，字段与 igorm、iredis、imongo、ielastic、trace、ilog 的构造函数一致，
+// 服务只需要在自己的配置中嵌入 Bootstrap 并增加业务相关的字段
+//
+//	type Bootstrap struct {
+//		conf.Bootstrap
+//		Business *Business `json:"business"`
+//	}
+package conf
+
+import (
+	"io"
+	"time"
+
+	"github.com/airunny/wiki-go-tools/ielastic"
+	"github.com/airunny/wiki-go-tools/igorm"
+	"github.com/airunny/wiki-go-tools/ilog"
+	"github.com/airunny/wiki-go-tools/imongo"
+	"github.com/airunny/wiki-go-tools/iredis"
+	itrace "github.com/airunny/wiki-go-tools/trace"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type (
+	Bootstrap struct {
+		Server *Server           `json:"server" description:"服务监听配置"`
+		Data   *Data             `json:"data" description:"存储配置"`
+		Kafka  map[string]*Kafka `json:"kafka" description:"kafka 配置，key 为业务自定义的名称"`
+		Trace  *Trace            `json:"trace" description:"链路追踪配置"`
+		Log    *Log              `json:"log" description:"日志配置"`
+	}
+
+	Server struct {
+		HTTP *Transport `json:"http" description:"http 服务"`
+		GRPC *Transport `json:"grpc" description:"grpc 服务"`
+	}
+
+	Transport struct {
+		Network string        `json:"network" default:"tcp" description:"监听的网络类型"`
+		Addr    string        `json:"addr" validate:"required" description:"监听地址，例如 0.0.0.0:8000"`
+		Timeout time.Duration `json:"timeout" default:"1s" description:"请求超时时间（纳秒）"`
+	}
+
+	Data struct {
+		Database *igorm.Config    `json:"database" description:"mysql 配置，对应 igorm.NewGORM"`
+		Redis    *iredis.Config   `json:"redis" description:"redis 配置，对应 iredis.NewClient"`
+		Mongo    *imongo.Config   `json:"mongo" description:"mongodb 配置，对应 imongo.NewClient"`
+		Elastic  *ielastic.Config `json:"elastic" description:"elasticsearch 配置，对应 ielastic.NewElastic"`
+	}
+
+	Kafka struct {
+		Brokers      []string `json:"brokers" validate:"required" description:"broker 地址列表"`
+		Topics       []string `json:"topics" description:"topic 列表"`
+		GroupID      string   `json:"group_id" description:"消费组"`
+		Username     string   `json:"username" description:"SASL 用户名"`
+		Password     string   `json:"password" description:"SASL 密码"`
+		Version      string   `json:"version" description:"kafka 版本，例如 2.8.0"`
+		OffsetOldest bool     `json:"offset_oldest" description:"没有提交过 offset 时从最早的消息开始消费"`
+	}
+
+	Trace struct {
+		Kind      string `json:"kind" default:"jaeger" description:"链路追踪类型，目前只支持 jaeger"`
+		Endpoint  string `json:"endpoint" description:"collector 地址，为空时不上报"`
+		Name      string `json:"name" description:"服务名称，默认使用环境变量 SERVICE_NAME"`
+		Namespace string `json:"namespace" description:"服务命名空间，默认使用环境变量 SERVICE_NAMESPACE"`
+		Version   string `json:"version" description:"服务版本，默认使用环境变量 SERVICE_VERSION"`
+		Fraction  string `json:"fraction" description:"采样比例"`
+	}
+
+	Log struct {
+		Console   bool `json:"console" description:"输出到标准输出"`
+		AccessLog bool `json:"access_log" description:"记录请求日志"`
+	}
+)
+
+// HTTPServerOptions returns the options of the kratos http server
+func (c *Transport) HTTPServerOptions() []http.ServerOption {
+	var opts []http.ServerOption
+	if c.Network != "" {
+		opts = append(opts, http.Network(c.Network))
+	}
+
+	if c.Addr != "" {
+		opts = append(opts, http.Address(c.Addr))
+	}
+
+	if c.Timeout > 0 {
+		opts = append(opts, http.Timeout(c.Timeout))
+	}
+	return opts
+}
+
+// GRPCServerOptions returns the options of the kratos grpc server
+func (c *Transport) GRPCServerOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if c.Network != "" {
+		opts = append(opts, grpc.Network(c.Network))
+	}
+
+	if c.Addr != "" {
+		opts = append(opts, grpc.Address(c.Addr))
+	}
+
+	if c.Timeout > 0 {
+		opts = append(opts, grpc.Timeout(c.Timeout))
+	}
+	return opts
+}
+
+func (c *Trace) Options() []itrace.Option {
+	var opts []itrace.Option
+	if c.Name != "" {
+		opts = append(opts, itrace.WithName(c.Name))
+	}
+
+	if c.Namespace != "" {
+		opts = append(opts, itrace.WithNamespace(c.Namespace))
+	}
+
+	if c.Version != "" {
+		opts = append(opts, itrace.WithVersion(c.Version))
+	}
+
+	if c.Fraction != "" {
+		opts = append(opts, itrace.WithFraction(c.Fraction))
+	}
+	return opts
+}
+
+// NewTracerProvider 对应 trace.NewTrace，Endpoint 为空时返回 noop
+func (c *Trace) NewTracerProvider() (trace.TracerProvider, error) {
+	return itrace.NewTrace(c.Kind, c.Endpoint, c.Options()...)
+}
+
+func (c *Log) Options() []ilog.Option {
+	var opts []ilog.Option
+	if c.Console {
+		opts = append(opts, ilog.WithConsole())
+	}
+
+	if c.AccessLog {
+		opts = append(opts, ilog.WithAccessLog())
+	}
+	return opts
+}
+
+// NewLogger 对应 ilog.NewLogger
+func (c *Log) NewLogger(id, name string) (log.Logger, io.Closer) {
+	return ilog.NewLogger(id, name, c.Options()...)
+}
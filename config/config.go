@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	apollo "github.com/go-kratos/kratos/contrib/config/apollo/v2"
 	"github.com/go-kratos/kratos/v2/config"
@@ -10,26 +11,54 @@ import (
 	"github.com/go-kratos/kratos/v2/encoding/yaml"
 )
 
-func apolloDecoder(value *config.KeyValue, m map[string]interface{}) error {
-	var tmp map[string]interface{}
-	err := encoding.GetCodec(yaml.Name).Unmarshal(value.Value, &tmp)
+// codecOf returns the codec of the format, yml is the same as yaml
+func codecOf(format string) (encoding.Codec, error) {
+	if format == formatYML {
+		format = yaml.Name
+	}
+
+	codec := encoding.GetCodec(format)
+	if codec == nil {
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+	return codec, nil
+}
+
+func defaultDecoder(value *config.KeyValue, m map[string]interface{}) error {
+	codec, err := codecOf(value.Format)
 	if err != nil {
-		return err
+		return fmt.Errorf("key: %s %w", value.Key, err)
 	}
+	return codec.Unmarshal(value.Value, &m)
+}
 
-	for k, v := range tmp {
-		vm, ok := v.(map[string]interface{})
-		if ok {
-			for vk, vv := range vm {
-				m[vk] = vv
-			}
-			continue
+// newDecoder decrypts the enc: values after decoding, so that the plaintext never appears in the sources.
+// The inspector records the values before decrypting.
+func newDecoder(keyring *Keyring, inspector *Inspector) config.Decoder {
+	return func(value *config.KeyValue, m map[string]interface{}) error {
+		if err := defaultDecoder(value, m); err != nil {
+			return err
+		}
+
+		var raw map[string]interface{}
+		if inspector != nil {
+			raw = cloneValues(m)
+		}
+
+		if err := decryptValues(keyring, m); err != nil {
+			return err
+		}
+
+		if inspector != nil {
+			inspector.record(value, raw)
 		}
-		m[k] = v
+		return nil
 	}
-	return nil
 }
 
+// LoadConfig 加载配置并解析到 v 中，优先级：默认值(default 标签) < 文件 < apollo < 环境变量(env 标签) < 命令行参数
+// 解析之后会校验配置，存在不合法的字段时返回 *ValidationError
+// enc: 前缀的值会使用 AES-GCM 解密，密钥见 WithKeyring、WithSecretKeyFile 以及 CONFIG_SECRET_KEYS 环境变量
 func LoadConfig(v interface{}, opts ...Option) (config.Config, error) {
 	o := defaultOptions()
 	for _, opt := range opts {
@@ -40,71 +69,46 @@ func LoadConfig(v interface{}, opts ...Option) (config.Config, error) {
 		return nil, fmt.Errorf("file and apollo is empty")
 	}
 
-	var configOpts []config.Option
-	if o.filePath != "" {
-		configOpts = append(configOpts, config.WithSource(file.NewSource(o.filePath)))
-	}
-
-	if o.apolloEndpoint != "" {
-		configOpts = append(configOpts, config.WithSource(
-			apollo.NewSource(
-				apollo.WithAppID(o.apolloAppID),
-				apollo.WithCluster(o.apolloCluster),
-				apollo.WithEndpoint(o.apolloEndpoint),
-				apollo.WithNamespace(o.apolloNamespace),
-				apollo.WithSecret(o.apolloSecret),
-			),
-		),
-			config.WithDecoder(apolloDecoder))
-	}
-
-	c := config.New(configOpts...)
-
-	if err := c.Load(); err != nil {
+	keyring, err := o.loadKeyring()
+	if err != nil {
 		return nil, err
 	}
 
-	if o.watchers != nil {
-		for key, observer := range o.watchers {
-			err := c.Watch(key, observer)
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-
-	return c, c.Scan(v)
-}
-
-func LoadConfigWithWatcher(v interface{}, opts ...Option) (config.Config, error) {
-	o := defaultOptions()
-	for _, opt := range opts {
-		opt(o)
+	var overrides []config.Source
+	if !o.disableEnv {
+		overrides = append(overrides, newEnvSource(v))
 	}
 
-	if o.filePath == "" && o.apolloEndpoint == "" {
-		return nil, fmt.Errorf("file and apollo is empty")
+	if o.flagSet != nil {
+		overrides = append(overrides, newFlagSource(o.flagSet, v))
 	}
 
-	var configOpts []config.Option
+	var sources []config.Source
 	if o.filePath != "" {
-		configOpts = append(configOpts, config.WithSource(file.NewSource(o.filePath)))
+		sources = append(sources, withOverrides(file.NewSource(o.filePath), overrides))
 	}
 
 	if o.apolloEndpoint != "" {
-		configOpts = append(configOpts, config.WithSource(
-			apollo.NewSource(
-				apollo.WithAppID(o.apolloAppID),
-				apollo.WithCluster(o.apolloCluster),
-				apollo.WithEndpoint(o.apolloEndpoint),
-				apollo.WithNamespace(o.apolloNamespace),
-				apollo.WithSecret(o.apolloSecret),
-			),
-		),
-			config.WithDecoder(apolloDecoder))
+		namespaces := o.namespaces()
+		source := apollo.NewSource(
+			apollo.WithAppID(o.apolloAppID),
+			apollo.WithCluster(o.apolloCluster),
+			apollo.WithEndpoint(o.apolloEndpoint),
+			apollo.WithNamespace(strings.Join(namespaces, ",")),
+			apollo.WithSecret(o.apolloSecret),
+			apollo.WithOriginalConfig(),
+		)
+		sources = append(sources, withOverrides(
+			newSnapshotSource(newApolloSource(source, namespaces, o.namespacePrefix), o.snapshotPath, o.snapshotMaxAge),
+			overrides,
+		))
 	}
+	sources = append(sources, overrides...)
 
-	c := config.New(configOpts...)
+	c := config.New(
+		config.WithSource(sources...),
+		config.WithDecoder(newDecoder(keyring, o.inspector)),
+	)
 
 	if err := c.Load(); err != nil {
 		return nil, err
@@ -119,5 +123,21 @@ func LoadConfigWithWatcher(v interface{}, opts ...Option) (config.Config, error)
 		}
 	}
 
-	return c, c.Scan(v)
+	if o.inspector != nil {
+		o.inspector.bind(c, v)
+	}
+
+	defaults, err := scanWithDefaults(v, c.Scan)
+	if err != nil {
+		return c, err
+	}
+
+	if o.inspector != nil && len(defaults) > 0 {
+		o.inspector.record(&config.KeyValue{Key: defaultsSourceKey}, defaults)
+	}
+	return c, Validate(v)
+}
+
+func LoadConfigWithWatcher(v interface{}, opts ...Option) (config.Config, error) {
+	return LoadConfig(v, opts...)
 }
@@ -0,0 +1,503 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/airunny/wiki-go-tools/config/conf"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/igorm"
+	"github.com/airunny/wiki-go-tools/iredis"
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type testBootstrap struct {
+	Database *igorm.Config  `json:"database"`
+	Redis    *iredis.Config `json:"redis"`
+	Name     string         `json:"name" env:"TEST_APP_NAME" default:"app"`
+	Timeout  time.Duration  `json:"timeout" default:"3s"`
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+database:
+  user: file_user
+  password: file_password
+  max_open: 5
+redis:
+  address: 127.0.0.1:6379
+name: file_app
+`)
+	t.Setenv("DP_MYSQL_DB_USER", "env_user")
+	t.Setenv("DP_MYSQL_DB_PASSWORD", "env_password")
+	t.Setenv("DP_REDIS_DB_SENTINEL_ADDRESS", "10.0.0.1:26379,10.0.0.2:26379")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("database.user", "", "")
+	assert.Nil(t, fs.Parse([]string{"-database.user=flag_user"}))
+
+	var bc testBootstrap
+	_, err := LoadConfig(&bc, WithFilePath(path), WithFlags(fs))
+	assert.Nil(t, err)
+
+	// flags > env > file > defaults
+	assert.Equal(t, "flag_user", bc.Database.User)
+	assert.Equal(t, "env_password", bc.Database.Password)
+	assert.Equal(t, 5, bc.Database.MaxOpen)
+	assert.Equal(t, 10, bc.Database.MaxIdle)
+	assert.Equal(t, []string{"10.0.0.1:26379", "10.0.0.2:26379"}, bc.Redis.SentinelAddress)
+	assert.Equal(t, "127.0.0.1:6379", bc.Redis.Address)
+	assert.Equal(t, "file_app", bc.Name)
+	assert.Equal(t, time.Second*3, bc.Timeout)
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	// 没有配置的指针子结构不使用默认值
+	var bc testBootstrap
+	_, err := LoadConfig(&bc, WithFilePath(writeConfigFile(t, "name: app\n")))
+	assert.Nil(t, err)
+	assert.Nil(t, bc.Database)
+	assert.Nil(t, bc.Redis)
+	assert.Equal(t, time.Second*3, bc.Timeout)
+
+	bc = testBootstrap{}
+	_, err = LoadConfig(&bc, WithFilePath(writeConfigFile(t, "database:\n  domain: x\n")))
+	assert.Nil(t, err)
+	assert.Equal(t, "x", bc.Database.Domain)
+	assert.Equal(t, 100, bc.Database.MaxOpen)
+	assert.Equal(t, time.Hour*8, bc.Database.MaxLifeTime)
+	assert.Nil(t, bc.Redis)
+
+	// 时长必须带单位
+	t.Setenv("DP_MYSQL_DB_MAX_LIFE_TIME", "8")
+	_, err = LoadConfig(&testBootstrap{}, WithFilePath(writeConfigFile(t, "name: app\n")))
+	assert.ErrorContains(t, err, "DP_MYSQL_DB_MAX_LIFE_TIME")
+}
+
+func TestLoadConfigInvalidEnv(t *testing.T) {
+	path := writeConfigFile(t, "name: app\n")
+	t.Setenv("DP_MYSQL_DB_MAX_OPEN", "abc")
+
+	var bc testBootstrap
+	_, err := LoadConfig(&bc, WithFilePath(path))
+	assert.ErrorContains(t, err, "DP_MYSQL_DB_MAX_OPEN")
+}
+
+type testServer struct {
+	Addr string
+}
+
+type testServerValidationError struct {
+	field  string
+	reason string
+}
+
+func (e testServerValidationError) Field() string  { return e.field }
+func (e testServerValidationError) Reason() string { return e.reason }
+func (e testServerValidationError) Cause() error   { return nil }
+func (e testServerValidationError) Error() string  { return e.field + ": " + e.reason }
+
+type testServerMultiError []error
+
+func (m testServerMultiError) AllErrors() []error { return m }
+func (m testServerMultiError) Error() string      { return "multi error" }
+
+// ValidateAll 模拟 protoc-gen-validate 生成的校验方法
+func (m *testServer) ValidateAll() error {
+	if m.Addr == "" {
+		return testServerMultiError{testServerValidationError{field: "Addr", reason: "value length must be at least 1 runes"}}
+	}
+	return nil
+}
+
+type testValidateBootstrap struct {
+	Server *testServer `json:"server"`
+	Name   string      `json:"name" validate:"required"`
+	Redis  struct {
+		Address string `json:"address" validate:"required,hostname_port"`
+	} `json:"redis"`
+}
+
+func TestLoadConfigValidate(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  addr: ""
+redis:
+  address: 127.0.0.1
+`)
+
+	var bc testValidateBootstrap
+	_, err := LoadConfig(&bc, WithFilePath(path))
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	paths := make([]string, 0, len(validationErr.Fields))
+	for _, field := range validationErr.Fields {
+		paths = append(paths, field.Path)
+	}
+	assert.ElementsMatch(t, []string{"server.Addr", "name", "redis.address"}, paths)
+}
+
+func TestWatch(t *testing.T) {
+	path := writeConfigFile(t, `
+redis:
+  address: 127.0.0.1:6379
+  db: 1
+name: app
+`)
+
+	var bc testBootstrap
+	c, err := LoadConfig(&bc, WithFilePath(path), WithDisableEnv())
+	assert.Nil(t, err)
+	defer c.Close()
+
+	changed := make(chan [2]*iredis.Config, 10)
+	sub, err := Watch(c, "redis", func(old, new *iredis.Config) {
+		changed <- [2]*iredis.Config{old, new}
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:6379", sub.Current().Address)
+
+	// 其他 key 变化不会触发回调
+	assert.Nil(t, os.WriteFile(path, []byte("redis:\n  address: 127.0.0.1:6379\n  db: 1\nname: app2\n"), 0644))
+	// redis 变化
+	assert.Nil(t, os.WriteFile(path, []byte("redis:\n  address: 127.0.0.1:6380\n  db: 1\nname: app2\n"), 0644))
+
+	select {
+	case values := <-changed:
+		assert.Equal(t, "127.0.0.1:6379", values[0].Address)
+		assert.Equal(t, "127.0.0.1:6380", values[1].Address)
+	case <-time.After(time.Second * 5):
+		t.Fatal("observer was not called")
+	}
+	assert.Equal(t, "127.0.0.1:6380", sub.Current().Address)
+	assert.Len(t, changed, 0)
+}
+
+func TestWatchProjects(t *testing.T) {
+	defer icontext.SetProjects(nil)
+
+	path := writeConfigFile(t, `
+projects:
+  "900":
+    project: wikiglobal
+`)
+	c, err := LoadConfig(&testBootstrap{}, WithFilePath(path), WithDisableEnv())
+	assert.Nil(t, err)
+	defer c.Close()
+
+	// 同一个 key 的其他订阅不会被覆盖
+	changed := make(chan map[string]*icontext.ProjectInfo, 1)
+	_, err = Watch(c, "projects", func(_, in map[string]*icontext.ProjectInfo) {
+		changed <- in
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, WatchProjects(c, "projects"))
+
+	info, ok := icontext.LookupProject("900")
+	assert.True(t, ok)
+	assert.Equal(t, "wikiglobal", info.Project)
+
+	assert.Nil(t, os.WriteFile(path, []byte("projects:\n  \"900\":\n    project: wikiglobal2\n"), 0644))
+	select {
+	case in := <-changed:
+		assert.Equal(t, "wikiglobal2", in["900"].Project)
+	case <-time.After(time.Second * 5):
+		t.Fatal("observer was not called")
+	}
+
+	assert.Eventually(t, func() bool {
+		info, ok = icontext.LookupProject("900")
+		return ok && info.Project == "wikiglobal2"
+	}, time.Second*5, time.Millisecond*10)
+}
+
+type testApolloSource struct {
+	kvs []*config.KeyValue
+	err error
+	ch  chan []*config.KeyValue
+}
+
+func (s *testApolloSource) Load() ([]*config.KeyValue, error) { return s.kvs, s.err }
+
+func (s *testApolloSource) Watch() (config.Watcher, error) { return s, nil }
+
+func (s *testApolloSource) Next() ([]*config.KeyValue, error) { return <-s.ch, nil }
+
+func (s *testApolloSource) Stop() error { return nil }
+
+func TestApolloNamespaces(t *testing.T) {
+	source := &testApolloSource{
+		kvs: []*config.KeyValue{
+			{Key: "application", Value: []byte(`{"application":{"name":"app","data":{"redis":{"db":1}}}}`), Format: "json"},
+			{Key: "data.yaml", Value: []byte("data:\n  redis:\n    address: 127.0.0.1:6379\n"), Format: "yaml"},
+			{Key: "server.json", Value: []byte(`{"server":{"http":{"addr":":8000"}}}`), Format: "json"},
+		},
+		ch: make(chan []*config.KeyValue, 1),
+	}
+
+	c := config.New(
+		config.WithSource(newApolloSource(source, []string{"application", "data.yaml", "server.json"}, false)),
+		config.WithDecoder(defaultDecoder),
+	)
+	assert.Nil(t, c.Load())
+	defer c.Close()
+
+	// 保留嵌套结构
+	address, _ := c.Value("data.redis.address").String()
+	assert.Equal(t, "127.0.0.1:6379", address)
+	db, _ := c.Value("data.redis.db").Int()
+	assert.Equal(t, int64(1), db)
+	addr, _ := c.Value("server.http.addr").String()
+	assert.Equal(t, ":8000", addr)
+
+	// 不同 namespace 定义了相同的 key
+	source.kvs[1].Value = []byte("data:\n  redis:\n    db: 2\n")
+	_, err := newApolloSource(source, nil, false).Load()
+	assert.ErrorContains(t, err, "data.redis.db")
+
+	// namespace 前缀
+	kvs, err := newApolloSource(source, nil, true).Load()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"application":{"name":"app","data":{"redis":{"db":1}}},"data":{"data":{"redis":{"db":2}}},"server":{"server":{"http":{"addr":":8000"}}}}`, string(kvs[0].Value))
+
+	// properties 变更只包含变化的 key
+	source.kvs[1].Value = []byte("data:\n  redis:\n    address: 127.0.0.1:6379\n")
+	w, err := newApolloSource(source, nil, false).Watch()
+	assert.Nil(t, err)
+	source.ch <- []*config.KeyValue{{Key: "application", Value: []byte(`{"application":{"name":"app2"}}`), Format: "json"}}
+	kvs, err = w.Next()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"name":"app2","data":{"redis":{"db":1,"address":"127.0.0.1:6379"}},"server":{"http":{"addr":":8000"}}}`, string(kvs[0].Value))
+
+	// 带 . 的 properties namespace 按 . 嵌套
+	values, err := decodeNamespace(&config.KeyValue{Key: "TEST1.common", Value: []byte(`{"TEST1":{"common":{"name":"common"}}}`), Format: "json"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "common"}, values)
+
+	_, err = decodeNamespace(&config.KeyValue{Key: "TEST1.common", Value: []byte(`{"TEST1.common":{"name":"common"}}`), Format: "json"})
+	assert.ErrorContains(t, err, "TEST1.common")
+
+	source = &testApolloSource{
+		kvs: []*config.KeyValue{
+			{Key: "TEST1.common", Value: []byte(`{"TEST1":{"common":{"name":"common"}}}`), Format: "json"},
+		},
+	}
+	kvs, err = newApolloSource(source, nil, true).Load()
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"TEST1":{"common":{"name":"common"}}}`, string(kvs[0].Value))
+}
+
+func TestLoadConfigSecret(t *testing.T) {
+	keyring := NewKeyring()
+	assert.Nil(t, keyring.Add("k1", []byte("0123456789abcdef")))
+	assert.Nil(t, keyring.Add("k2", []byte("0123456789abcdef0123456789abcdef")))
+
+	// 使用旧的密钥加密的值在轮换后依然可以解密
+	password, err := keyring.Encrypt("db_password")
+	assert.Nil(t, err)
+	assert.Nil(t, keyring.SetPrimary("k2"))
+	address, err := keyring.Encrypt("127.0.0.1:6379")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(address, "enc:k2:"))
+
+	path := writeConfigFile(t, fmt.Sprintf("database:\n  password: %s\nredis:\n  address: %s\n", password, address))
+
+	var bc testBootstrap
+	_, err = LoadConfig(&bc, WithFilePath(path), WithDisableEnv(), WithKeyring(keyring))
+	assert.Nil(t, err)
+	assert.Equal(t, "db_password", bc.Database.Password)
+	assert.Equal(t, "127.0.0.1:6379", bc.Redis.Address)
+
+	// 缺少密钥时返回错误，错误中不包含明文
+	old := NewKeyring()
+	assert.Nil(t, old.Add("k1", []byte("0123456789abcdef")))
+	_, err = LoadConfig(&bc, WithFilePath(path), WithDisableEnv(), WithKeyring(old))
+	assert.ErrorIs(t, err, ErrSecretKeyNotFound)
+	assert.ErrorContains(t, err, "redis.address")
+	assert.NotContains(t, err.Error(), "127.0.0.1")
+
+	t.Setenv(SecretKeysEnvName, "")
+	t.Setenv(SecretKeyFileEnvName, "")
+	_, err = LoadConfig(&bc, WithFilePath(path), WithDisableEnv())
+	assert.ErrorContains(t, err, "no secret key")
+}
+
+func TestSnapshotSource(t *testing.T) {
+	var (
+		path   = filepath.Join(t.TempDir(), "snapshot", "config.json")
+		source = &testApolloSource{
+			kvs: []*config.KeyValue{{Key: "apollo", Value: []byte(`{"name":"app"}`), Format: "json"}},
+		}
+	)
+
+	// 没有快照时返回原始错误
+	source.err = errors.New("apollo is down")
+	_, err := newSnapshotSource(source, path, 0).Load()
+	assert.ErrorContains(t, err, "apollo is down")
+
+	// 加载成功后保存快照
+	source.err = nil
+	_, err = newSnapshotSource(source, path, 0).Load()
+	assert.Nil(t, err)
+
+	source.err, source.kvs = errors.New("apollo is down"), nil
+	kvs, err := newSnapshotSource(source, path, time.Hour).Load()
+	assert.Nil(t, err)
+	assert.Len(t, kvs, 1)
+	assert.JSONEq(t, `{"name":"app"}`, string(kvs[0].Value))
+
+	// 快照过期
+	time.Sleep(time.Millisecond * 10)
+	_, err = newSnapshotSource(source, path, time.Millisecond).Load()
+	assert.ErrorContains(t, err, "stale")
+}
+
+func TestInspector(t *testing.T) {
+	keyring := NewKeyring()
+	assert.Nil(t, keyring.Add("k1", []byte("0123456789abcdef")))
+	address, err := keyring.Encrypt("127.0.0.1:6379")
+	assert.Nil(t, err)
+
+	path := writeConfigFile(t, fmt.Sprintf(`
+database:
+  user: file_user
+  password: plain
+redis:
+  address: %s
+consumers:
+  - name: consumer
+    password: consumer_password
+`, address))
+	t.Setenv("TEST_APP_NAME", "env_app")
+
+	var (
+		bc        testBootstrap
+		inspector = NewInspector()
+	)
+	_, err = LoadConfig(&bc, WithFilePath(path), WithKeyring(keyring), WithInspector(inspector))
+	assert.Nil(t, err)
+
+	keys, err := inspector.Keys()
+	assert.Nil(t, err)
+
+	infos := make(map[string]*KeyInfo, len(keys))
+	for _, key := range keys {
+		infos[key.Key] = key
+	}
+
+	assert.Equal(t, SourceFile, infos["database.user"].Source)
+	assert.Equal(t, "file_user", infos["database.user"].Value)
+	assert.False(t, infos["database.user"].UpdatedAt.IsZero())
+	assert.Equal(t, SourceEnv, infos["name"].Source)
+	assert.Equal(t, "env_app", infos["name"].Value)
+	assert.Equal(t, SourceDefaults, infos["database.max_open"].Source)
+	// 敏感字段名称以及加密的值都会被隐藏
+	assert.Equal(t, redacted, infos["database.password"].Value)
+	assert.Equal(t, redacted, infos["redis.address"].Value)
+	assert.Equal(t, redacted, infos["consumers"].Value)
+
+	w := httptest.NewRecorder()
+	inspector.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/config", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "127.0.0.1")
+	assert.NotContains(t, w.Body.String(), "plain")
+	assert.NotContains(t, w.Body.String(), "consumer_password")
+}
+
+func TestJSONSchema(t *testing.T) {
+	data, err := JSONSchema(&conf.Bootstrap{})
+	assert.Nil(t, err)
+
+	var schema struct {
+		Properties map[string]struct {
+			Properties map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+				Required   []string                          `json:"required"`
+			} `json:"properties"`
+		} `json:"properties"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &schema))
+
+	transport := schema.Properties["server"].Properties["http"]
+	assert.Equal(t, []string{"addr"}, transport.Required)
+	assert.Equal(t, "tcp", transport.Properties["network"]["default"])
+	assert.Equal(t, float64(time.Second), transport.Properties["timeout"]["default"])
+	assert.NotEmpty(t, transport.Properties["addr"]["description"])
+
+	// tls.Config 不出现在 schema 中
+	redis := schema.Properties["data"].Properties["redis"]
+	assert.Contains(t, redis.Properties, "address")
+	assert.NotContains(t, redis.Properties, "tls_config")
+}
+
+// testEmbedBootstrap 服务的配置嵌入 conf.Bootstrap
+type testEmbedBootstrap struct {
+	conf.Bootstrap
+	Business struct {
+		Name string `json:"name" default:"business"`
+	} `json:"business"`
+}
+
+func TestEmbedBootstrap(t *testing.T) {
+	// 只有 http 服务时 grpc 为 nil
+	path := writeConfigFile(t, `
+server:
+  http:
+    addr: 0.0.0.0:8000
+`)
+
+	var bc testEmbedBootstrap
+	_, err := LoadConfig(&bc, WithFilePath(path))
+	assert.Nil(t, err)
+	assert.Equal(t, "0.0.0.0:8000", bc.Server.HTTP.Addr)
+	assert.Equal(t, "tcp", bc.Server.HTTP.Network)
+	assert.Equal(t, time.Second, bc.Server.HTTP.Timeout)
+	assert.Nil(t, bc.Server.GRPC)
+	assert.Nil(t, bc.Data)
+	assert.Equal(t, "business", bc.Business.Name)
+
+	// 校验失败的字段路径与配置文件一致
+	path = writeConfigFile(t, `
+server:
+  http:
+    addr: 0.0.0.0:8000
+  grpc:
+    network: tcp
+`)
+
+	bc = testEmbedBootstrap{}
+	_, err = LoadConfig(&bc, WithFilePath(path))
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "server.grpc.addr", validationErr.Fields[0].Path)
+
+	data, err := JSONSchema(&testEmbedBootstrap{})
+	assert.Nil(t, err)
+
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &schema))
+	assert.Contains(t, schema.Properties, "server")
+	assert.Contains(t, schema.Properties, "business")
+	assert.NotContains(t, schema.Properties, "Bootstrap")
+}
@@ -0,0 +1,264 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/config"
+)
+
+const (
+	SourceDefaults = "defaults"
+	SourceFile     = "file"
+	SourceApollo   = "apollo"
+	SourceEnv      = "env"
+	SourceFlags    = "flags"
+
+	secretTagName = "secret"
+	redacted      = "******"
+)
+
+// sourcePriority from high to low
+var sourcePriority = []string{SourceFlags, SourceEnv, SourceApollo, SourceFile, SourceDefaults}
+
+// secretNames 字段名包含这些关键字时视为敏感字段
+var secretNames = []string{"password", "passwd", "secret", "token", "credential", "private_key", "access_key", "api_key"}
+
+// Inspector 记录每个配置的来源以及最后变更的时间，ServeHTTP 输出当前生效的配置，敏感字段会被隐藏；
+// 隐藏只按字段名以及 secret 标签匹配，输出的配置依然包含内部地址等信息，必须放在认证之后，不能对外暴露
+//
+//	inspector := config.NewInspector()
+//	c, err := config.LoadConfig(&bc, config.WithInspector(inspector))
+//	httpSrv.Handle("/debug/config", adminAuth(inspector))
+type Inspector struct {
+	mu      sync.Mutex
+	c       config.Config
+	secrets map[string]struct{}
+	values  map[string]map[string]interface{} // source => path => value
+	changed map[string]time.Time
+}
+
+// KeyInfo is the effective value of a config key
+type KeyInfo struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	Source    string      `json:"source"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+func NewInspector() *Inspector {
+	return &Inspector{
+		secrets: make(map[string]struct{}),
+		values:  make(map[string]map[string]interface{}),
+		changed: make(map[string]time.Time),
+	}
+}
+
+// bind binds the loaded config, the fields of v tagged `secret:"true"` are redacted
+func (i *Inspector) bind(c config.Config, v interface{}) {
+	secrets := make(map[string]struct{})
+	_ = walkTags(reflect.TypeOf(v), secretTagName, func(path []string, _ reflect.StructField, value string) error {
+		if value == "true" {
+			secrets[strings.Join(path, ".")] = struct{}{}
+		}
+		return nil
+	})
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.c = c
+	i.secrets = secrets
+}
+
+// record records the values of the KeyValue before decrypting
+func (i *Inspector) record(kv *config.KeyValue, values map[string]interface{}) {
+	var (
+		source = sourceOf(kv.Key)
+		flat   = make(map[string]interface{})
+		now    = time.Now()
+	)
+	flattenValues(values, "", flat)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	previous := i.values[source]
+	for path, value := range flat {
+		if old, ok := previous[path]; !ok || !reflect.DeepEqual(old, value) {
+			i.changed[path] = now
+		}
+	}
+
+	// 同一个 source 的 KeyValue 合并记录，例如文件 source 中的多个文件
+	if previous == nil {
+		previous = make(map[string]interface{}, len(flat))
+		i.values[source] = previous
+	}
+	for path, value := range flat {
+		previous[path] = value
+	}
+}
+
+// Keys returns the effective config keys in sorted order
+func (i *Inspector) Keys() ([]*KeyInfo, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	c := i.c
+	if c == nil {
+		return nil, errors.New("config is not loaded")
+	}
+
+	paths := make(map[string]struct{})
+	for _, values := range i.values {
+		for path := range values {
+			paths[path] = struct{}{}
+		}
+	}
+
+	// 使用 config 中的值，即解密以及替换占位符之后实际生效的值
+	flat := make(map[string]interface{}, len(paths))
+	for path := range paths {
+		if value := c.Value(path).Load(); value != nil {
+			flat[path] = value
+		} else if value, ok := i.values[SourceDefaults][path]; ok {
+			// 默认值在解析之后补全，不在 config 中
+			flat[path] = value
+		}
+	}
+
+	out := make([]*KeyInfo, 0, len(flat))
+	for path, value := range flat {
+		info := &KeyInfo{
+			Key:       path,
+			Value:     value,
+			UpdatedAt: i.changed[path],
+		}
+
+		var encrypted bool
+		for _, source := range sourcePriority {
+			if raw, ok := i.values[source][path]; ok {
+				info.Source = source
+				encrypted = isSecretValue(raw)
+				break
+			}
+		}
+
+		if encrypted || i.isSecret(path) || hasSecretKey(value) {
+			info.Value = redacted
+		}
+		out = append(out, info)
+	}
+
+	sort.Slice(out, func(a, b int) bool {
+		return out[a].Key < out[b].Key
+	})
+	return out, nil
+}
+
+func (i *Inspector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	keys, err := i.Keys()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": keys,
+	})
+}
+
+func (i *Inspector) isSecret(path string) bool {
+	if _, ok := i.secrets[path]; ok {
+		return true
+	}
+
+	return isSecretName(path[strings.LastIndex(path, ".")+1:])
+}
+
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	if name == "key" {
+		return true
+	}
+
+	for _, secret := range secretNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSecretKey reports whether the value contains a secret field, the slices are not flattened,
+// eg: consumers: [{name: a, password: b}]
+func hasSecretKey(value interface{}) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if hasSecretKey(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSecretName(key) || hasSecretKey(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isSecretValue(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return IsSecret(v)
+	case []interface{}:
+		for _, item := range v {
+			if isSecretValue(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if isSecretValue(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sourceOf(key string) string {
+	switch key {
+	case defaultsSourceKey:
+		return SourceDefaults
+	case envSourceKey:
+		return SourceEnv
+	case flagSourceKey:
+		return SourceFlags
+	case apolloSourceKey:
+		return SourceApollo
+	}
+	return SourceFile
+}
+
+// flattenValues flattens the nested maps into dotted paths, the slices are kept as values
+func flattenValues(values map[string]interface{}, prefix string, out map[string]interface{}) {
+	for key, value := range values {
+		path := joinPath(prefix, key)
+		if sub, ok := value.(map[string]interface{}); ok && len(sub) > 0 {
+			flattenValues(sub, path, out)
+			continue
+		}
+		out[path] = value
+	}
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"flag"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/go-kratos/kratos/v2/config"
 )
@@ -13,7 +16,15 @@ type options struct {
 	apolloEndpoint  string
 	apolloNamespace string
 	apolloSecret    string
+	namespacePrefix bool
 	watchers        map[string]config.Observer
+	flagSet         *flag.FlagSet
+	disableEnv      bool
+	keyring         *Keyring
+	secretKeyFile   string
+	snapshotPath    string
+	snapshotMaxAge  time.Duration
+	inspector       *Inspector
 }
 
 type Option func(*options)
@@ -48,18 +59,105 @@ func WithEndpoint(in string) Option {
 	}
 }
 
+// WithNamespace apollo namespace，多个 namespace 使用逗号分隔，格式根据后缀推断，例如 application,data.yaml,server.json
 func WithNamespace(in string) Option {
 	return func(o *options) {
 		o.apolloNamespace = in
 	}
 }
 
+// WithNamespaces 同时加载多个 apollo namespace，不同 namespace 中定义了相同的 key 时返回错误
+func WithNamespaces(in ...string) Option {
+	return func(o *options) {
+		o.apolloNamespace = strings.Join(in, ",")
+	}
+}
+
+// WithNamespacePrefix 将每个 namespace 的配置放在 namespace 名称(不包含后缀)下，例如 data.yaml 中的 redis 对应 data.redis
+func WithNamespacePrefix() Option {
+	return func(o *options) {
+		o.namespacePrefix = true
+	}
+}
+
 func WithSecret(in string) Option {
 	return func(o *options) {
 		o.apolloSecret = in
 	}
 }
 
+// WithFlags 使用命令行参数覆盖配置，flag 名称为配置路径，例如 -data.redis.address，优先级最高
+// fs 需要在 LoadConfig 之前完成 Parse
+func WithFlags(fs *flag.FlagSet) Option {
+	return func(o *options) {
+		o.flagSet = fs
+	}
+}
+
+// WithDisableEnv 不使用 env 标签对应的环境变量覆盖配置
+func WithDisableEnv() Option {
+	return func(o *options) {
+		o.disableEnv = true
+	}
+}
+
+// WithKeyring 用于解密 enc: 前缀的配置
+func WithKeyring(keyring *Keyring) Option {
+	return func(o *options) {
+		o.keyring = keyring
+	}
+}
+
+// WithSecretKeyFile 从文件加载解密 enc: 前缀配置的密钥，每行一个 id:base64key
+func WithSecretKeyFile(path string) Option {
+	return func(o *options) {
+		o.secretKeyFile = path
+	}
+}
+
+// WithSnapshot 每次从 apollo 加载成功后将配置保存到 path，apollo 不可用时使用该快照启动
+func WithSnapshot(path string) Option {
+	return func(o *options) {
+		o.snapshotPath = path
+	}
+}
+
+// WithSnapshotMaxAge 快照超过 maxAge 时不再使用，默认不限制
+func WithSnapshotMaxAge(maxAge time.Duration) Option {
+	return func(o *options) {
+		o.snapshotMaxAge = maxAge
+	}
+}
+
+// WithInspector 记录配置的来源和变更时间，用于排查线上配置
+func WithInspector(inspector *Inspector) Option {
+	return func(o *options) {
+		o.inspector = inspector
+	}
+}
+
+func (o *options) loadKeyring() (*Keyring, error) {
+	if o.keyring != nil {
+		return o.keyring, nil
+	}
+
+	if o.secretKeyFile != "" {
+		return LoadKeyringFile(o.secretKeyFile)
+	}
+	return LoadKeyringFromEnv()
+}
+
+func (o *options) namespaces() []string {
+	var out []string
+	for _, namespace := range strings.Split(o.apolloNamespace, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace != "" {
+			out = append(out, namespace)
+		}
+	}
+	return out
+}
+
 func defaultOptions() *options {
 	var (
 		cluster    = "default"
@@ -76,5 +174,6 @@ func defaultOptions() *options {
 		apolloEndpoint:  os.Getenv("APOLLO_ENDPOINT"),
 		apolloNamespace: os.Getenv("APOLLO_NAMESPACE"),
 		apolloSecret:    os.Getenv("APOLLO_SECRET"),
+		snapshotPath:    os.Getenv(SnapshotPathEnvName),
 	}
 }
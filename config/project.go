@@ -0,0 +1,27 @@
+package config
+
+import (
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/go-kratos/kratos/v2/config"
+)
+
+// WatchProjects 从配置中加载 app id 对应的项目信息（icontext.SetProjects）并在配置变更时更新，可以与同一个 key 的其他订阅共存，
+// 配置格式为 app id => icontext.ProjectInfo
+//
+//	projects:
+//	  "500":
+//	    project: wikistock
+//	    brand: WikiStock
+//	    platform_family: app
+//	    default_language: zh-cn
+func WatchProjects(c config.Config, key string) error {
+	sub, err := Watch(c, key, func(_, in map[string]*icontext.ProjectInfo) {
+		icontext.SetProjects(in)
+	})
+	if err != nil {
+		return err
+	}
+
+	icontext.SetProjects(sub.Current())
+	return nil
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	schemaVersion  = "https://json-schema.org/draft/2020-12/schema"
+	descriptionTag = "description"
+)
+
+// schemaSkipTypes are only set in code, eg: the tls.Config is built from the ca_path
+var schemaSkipTypes = map[reflect.Type]struct{}{
+	reflect.TypeOf(tls.Config{}): {},
+}
+
+// JSONSchema 根据配置结构体生成 JSON Schema，用于编辑器补全和校验配置文件
+// 字段名称使用 json 标签，default 标签作为默认值，validate 标签包含 required 时为必填，description 标签作为说明
+func JSONSchema(v interface{}) ([]byte, error) {
+	schema := typeSchema(reflect.TypeOf(v), map[reflect.Type]bool{})
+	schema["$schema"] = schemaVersion
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func typeSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	if t == durationType {
+		return map[string]interface{}{"type": "integer"}
+	}
+
+	t = indirectType(t)
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), visiting),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), visiting),
+		}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+
+		visiting[t] = true
+		defer delete(visiting, t)
+		return structSchema(t, visiting)
+	}
+
+	// interface 等类型不做限制
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	var (
+		properties = map[string]interface{}{}
+		required   []string
+	)
+
+	// 嵌入的结构体与 encoding/json 一致，字段提升到当前结构体，当前结构体的同名字段优先
+	promotedRequired := map[string]struct{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !promoted(field) {
+			continue
+		}
+
+		sub := typeSchema(field.Type, visiting)
+		subProperties, _ := sub["properties"].(map[string]interface{})
+		for name, schema := range subProperties {
+			if _, ok := properties[name]; !ok {
+				properties[name] = schema
+			}
+		}
+
+		subRequired, _ := sub["required"].([]string)
+		for _, name := range subRequired {
+			promotedRequired[name] = struct{}{}
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if promoted(field) || !field.IsExported() || skipSchemaField(field.Type) {
+			continue
+		}
+
+		name := fieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		schema := typeSchema(field.Type, visiting)
+		if description := field.Tag.Get(descriptionTag); description != "" {
+			schema["description"] = description
+		}
+
+		if raw, ok := field.Tag.Lookup(defaultTagName); ok {
+			if value, err := convertValue(field.Type, raw); err == nil {
+				schema["default"] = value
+			}
+		}
+
+		if isRequired(field) {
+			required = append(required, name)
+		}
+		delete(promotedRequired, name)
+		properties[name] = schema
+	}
+
+	for name := range promotedRequired {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func skipSchemaField(t reflect.Type) bool {
+	t = indirectType(t)
+	if _, ok := schemaSkipTypes[t]; ok {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+func isRequired(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// SecretPrefix 加密配置的前缀，完整格式为 enc:<key id>:<base64(nonce + ciphertext)>
+	SecretPrefix = "enc:"
+	// SecretKeysEnvName 密钥列表，格式为 id1:base64key,id2:base64key
+	SecretKeysEnvName = "CONFIG_SECRET_KEYS"
+	// SecretKeyFileEnvName 密钥文件，每行一个 id:base64key，# 开头为注释
+	SecretKeyFileEnvName = "CONFIG_SECRET_KEY_FILE"
+)
+
+var (
+	ErrSecretKeyNotFound = errors.New("secret key not found")
+	ErrInvalidSecret     = errors.New("invalid secret")
+)
+
+// Keyring holds the AES keys by id, the first key is used to encrypt.
+// The old keys are kept in the keyring to decrypt the values which have not been re-encrypted yet.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring creates an empty keyring
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: make(map[string][]byte),
+	}
+}
+
+// Add adds the key, the key must be 16, 24 or 32 bytes, the first added key is the primary key
+func (k *Keyring) Add(id string, key []byte) error {
+	if id == "" || strings.Contains(id, ":") {
+		return fmt.Errorf("invalid secret key id %q", id)
+	}
+
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("invalid secret key %s: %w", id, err)
+	}
+
+	if k.primary == "" {
+		k.primary = id
+	}
+	k.keys[id] = key
+	return nil
+}
+
+// SetPrimary sets the key used to encrypt
+func (k *Keyring) SetPrimary(id string) error {
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrSecretKeyNotFound, id)
+	}
+	k.primary = id
+	return nil
+}
+
+// Primary returns the id of the key used to encrypt
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Len returns the number of the keys
+func (k *Keyring) Len() int {
+	return len(k.keys)
+}
+
+// Encrypt encrypts the plaintext with the primary key
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	key, ok := k.keys[k.primary]
+	if !ok {
+		return "", ErrSecretKeyNotFound
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	// key id 作为附加数据，避免密文被换到其他 key id 下
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.primary))
+	return SecretPrefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts the value with the key of the key id, the error never contains the value
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsSecret(value) {
+		return "", ErrInvalidSecret
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, SecretPrefix), ":")
+	if !ok {
+		return "", ErrInvalidSecret
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrSecretKeyNotFound, id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidSecret
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidSecret
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("%w: decrypt with key %s failed", ErrInvalidSecret, id)
+	}
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsSecret reports whether the value is encrypted
+func IsSecret(value string) bool {
+	return strings.HasPrefix(value, SecretPrefix)
+}
+
+// ParseKeyring parses the keys in the format of id1:base64key,id2:base64key or one key per line
+func ParseKeyring(data []byte) (*Keyring, error) {
+	k := NewKeyring()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		for _, item := range strings.Split(scanner.Text(), ",") {
+			item = strings.TrimSpace(item)
+			if item == "" || strings.HasPrefix(item, "#") {
+				continue
+			}
+
+			id, encoded, ok := strings.Cut(item, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid secret key, expected id:base64key")
+			}
+
+			key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+			if err != nil {
+				return nil, fmt.Errorf("invalid secret key %s: %w", id, err)
+			}
+
+			if err = k.Add(strings.TrimSpace(id), key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// LoadKeyringFile loads the keyring from the file
+func LoadKeyringFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyring(data)
+}
+
+// LoadKeyringFromEnv 从 CONFIG_SECRET_KEYS 或 CONFIG_SECRET_KEY_FILE 加载密钥，都没有设置时返回 nil
+func LoadKeyringFromEnv() (*Keyring, error) {
+	if keys := os.Getenv(SecretKeysEnvName); keys != "" {
+		return ParseKeyring([]byte(keys))
+	}
+
+	if path := os.Getenv(SecretKeyFileEnvName); path != "" {
+		return LoadKeyringFile(path)
+	}
+	return nil, nil
+}
+
+// decryptValues decrypts all the enc: values of the map in place
+func decryptValues(k *Keyring, values map[string]interface{}) error {
+	return decryptMap(k, values, nil)
+}
+
+func decryptMap(k *Keyring, values map[string]interface{}, path []string) error {
+	for key, value := range values {
+		out, err := decryptValue(k, value, append(path, key))
+		if err != nil {
+			return err
+		}
+		values[key] = out
+	}
+	return nil
+}
+
+func decryptValue(k *Keyring, value interface{}, path []string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !IsSecret(v) {
+			return v, nil
+		}
+
+		if k == nil {
+			return nil, fmt.Errorf("config %s is encrypted but no secret key is configured", strings.Join(path, "."))
+		}
+
+		plaintext, err := k.Decrypt(v)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", strings.Join(path, "."), err)
+		}
+		return plaintext, nil
+	case map[string]interface{}:
+		return v, decryptMap(k, v, path)
+	case []interface{}:
+		for i, item := range v {
+			itemPath := append(append([]string{}, path[:len(path)-1]...), fmt.Sprintf("%s[%d]", path[len(path)-1], i))
+			out, err := decryptValue(k, item, itemPath)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = out
+		}
+		return v, nil
+	}
+	return value, nil
+}
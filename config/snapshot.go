@@ -0,0 +1,155 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SnapshotPathEnvName 配置快照的路径
+const SnapshotPathEnvName = "CONFIG_SNAPSHOT_PATH"
+
+var metricSnapshotFallback = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "config",
+	Subsystem: "snapshot",
+	Name:      "fallback_total",
+	Help:      "The total number of config loads which fell back to the local snapshot",
+})
+
+func init() {
+	prometheus.MustRegister(metricSnapshotFallback)
+}
+
+type snapshot struct {
+	SavedAt time.Time          `json:"saved_at"`
+	KVs     []*config.KeyValue `json:"kvs"`
+}
+
+// snapshotSource saves the KeyValues after every successful load, and falls back to the snapshot when the source fails.
+// The values are saved before decoding, so the enc: secrets are still encrypted in the snapshot.
+type snapshotSource struct {
+	source config.Source
+	path   string
+	maxAge time.Duration
+}
+
+func newSnapshotSource(source config.Source, path string, maxAge time.Duration) config.Source {
+	if path == "" {
+		return source
+	}
+
+	return &snapshotSource{
+		source: source,
+		path:   path,
+		maxAge: maxAge,
+	}
+}
+
+func (s *snapshotSource) Load() ([]*config.KeyValue, error) {
+	kvs, err := s.source.Load()
+	if err == nil {
+		s.save(kvs)
+		return kvs, nil
+	}
+
+	snap, snapErr := s.restore()
+	if snapErr != nil {
+		return nil, fmt.Errorf("%w, and the snapshot is unavailable: %v", err, snapErr)
+	}
+
+	metricSnapshotFallback.Inc()
+	log.Warnf("config load failed: %v, fall back to the snapshot %s saved at %s", err, s.path, snap.SavedAt.Format(time.RFC3339))
+	return snap.KVs, nil
+}
+
+func (s *snapshotSource) Watch() (config.Watcher, error) {
+	w, err := s.source.Watch()
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshotWatcher{
+		Watcher: w,
+		source:  s,
+	}, nil
+}
+
+func (s *snapshotSource) save(kvs []*config.KeyValue) {
+	data, err := json.Marshal(&snapshot{
+		SavedAt: time.Now(),
+		KVs:     kvs,
+	})
+	if err != nil {
+		log.Warnf("marshal config snapshot failed: %v", err)
+		return
+	}
+
+	if err = writeFileAtomic(s.path, data); err != nil {
+		log.Warnf("save config snapshot %s failed: %v", s.path, err)
+	}
+}
+
+func (s *snapshotSource) restore() (*snapshot, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snap snapshot
+	if err = json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", s.path, err)
+	}
+
+	if age := time.Since(snap.SavedAt); s.maxAge > 0 && age > s.maxAge {
+		return nil, fmt.Errorf("snapshot %s is stale, saved %s ago", s.path, age.Truncate(time.Second))
+	}
+	return &snap, nil
+}
+
+type snapshotWatcher struct {
+	config.Watcher
+	source *snapshotSource
+}
+
+func (w *snapshotWatcher) Next() ([]*config.KeyValue, error) {
+	kvs, err := w.Watcher.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	// 变更可能只包含部分配置，重新加载完整的配置保存快照
+	if latest, err := w.source.source.Load(); err == nil {
+		w.source.save(latest)
+	}
+	return kvs, nil
+}
+
+// writeFileAtomic writes the file by renaming a temp file, so a crash never leaves a broken snapshot
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/config"
+)
+
+const (
+	defaultsSourceKey = "tag:defaults" // 只用于 Inspector 记录默认值的来源
+	envSourceKey      = "tag:env"
+	flagSourceKey     = "tag:flags"
+)
+
+// tagSource is a static source built from the struct tags, env or flags
+type tagSource struct {
+	key  string
+	load func() (map[string]interface{}, error)
+}
+
+func (s *tagSource) Load() ([]*config.KeyValue, error) {
+	values, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*config.KeyValue{
+		{
+			Key:    s.key,
+			Value:  data,
+			Format: "json",
+		},
+	}, nil
+}
+
+func (s *tagSource) Watch() (config.Watcher, error) {
+	return newStaticWatcher(), nil
+}
+
+// scanWithDefaults 解析配置之后使用 `default:"100"` 标签补全没有配置的字段，优先级最低；
+// 只补全配置中存在的子结构，没有配置的指针子结构（例如 data.database）依然为 nil
+func scanWithDefaults(v interface{}, scan func(interface{}) error) (map[string]interface{}, error) {
+	if err := scan(v); err != nil {
+		return nil, err
+	}
+
+	values, err := defaultValues(v)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先写入默认值再解析配置，配置中的值覆盖默认值
+	out := reflect.New(reflect.TypeOf(v).Elem())
+	if err = json.Unmarshal(data, out.Interface()); err != nil {
+		return nil, err
+	}
+
+	if err = scan(out.Interface()); err != nil {
+		return nil, err
+	}
+	reflect.ValueOf(v).Elem().Set(out.Elem())
+	return values, nil
+}
+
+// defaultValues returns the defaults of the fields whose parent struct exists in v
+func defaultValues(v interface{}) (map[string]interface{}, error) {
+	var (
+		rv     = reflect.ValueOf(v)
+		values = map[string]interface{}{}
+	)
+	err := walkTags(rv.Type(), defaultTagName, func(path []string, field reflect.StructField, raw string) error {
+		if !hasPath(rv, path[:len(path)-1]) {
+			return nil
+		}
+
+		value, err := convertValue(field.Type, raw)
+		if err != nil {
+			return fmt.Errorf("invalid default of %s: %w", strings.Join(path, "."), err)
+		}
+		setPath(values, path, value)
+		return nil
+	})
+	return values, err
+}
+
+// newEnvSource 使用 `env:"DP_MYSQL_DB_USER"` 标签对应的环境变量覆盖配置
+func newEnvSource(v interface{}) config.Source {
+	return &tagSource{
+		key: envSourceKey,
+		load: func() (map[string]interface{}, error) {
+			values := map[string]interface{}{}
+			err := walkTags(reflect.TypeOf(v), envTagName, func(path []string, field reflect.StructField, name string) error {
+				raw, ok := os.LookupEnv(name)
+				if !ok || name == "" {
+					return nil
+				}
+
+				value, err := convertValue(field.Type, raw)
+				if err != nil {
+					return fmt.Errorf("invalid env %s: %w", name, err)
+				}
+				setPath(values, path, value)
+				return nil
+			})
+			return values, err
+		},
+	}
+}
+
+// newFlagSource 使用命令行参数覆盖配置，flag 的名称为配置的路径，例如 -data.redis.address=127.0.0.1:6379
+// 只有显式设置的 flag 才会生效
+func newFlagSource(fs *flag.FlagSet, v interface{}) config.Source {
+	return &tagSource{
+		key: flagSourceKey,
+		load: func() (map[string]interface{}, error) {
+			var (
+				values = map[string]interface{}{}
+				err    error
+			)
+
+			fs.Visit(func(f *flag.Flag) {
+				if err != nil {
+					return
+				}
+
+				var (
+					path  = strings.Split(f.Name, ".")
+					raw   = f.Value.String()
+					value interface{}
+				)
+
+				typ, ok := lookupType(reflect.TypeOf(v), path)
+				if !ok {
+					return
+				}
+
+				value, err = convertValue(typ, raw)
+				if err != nil {
+					err = fmt.Errorf("invalid flag %s: %w", f.Name, err)
+					return
+				}
+				setPath(values, path, value)
+			})
+			return values, err
+		},
+	}
+}
+
+// staticWatcher never changes, Next blocks until Stop
+type staticWatcher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newStaticWatcher() *staticWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &staticWatcher{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (w *staticWatcher) Next() ([]*config.KeyValue, error) {
+	<-w.ctx.Done()
+	return nil, w.ctx.Err()
+}
+
+func (w *staticWatcher) Stop() error {
+	w.cancel()
+	return nil
+}
+
+// overrideSource re-applies the higher priority sources after every change of the source,
+// so that env and flags always win over the hot-reloaded file and apollo values.
+type overrideSource struct {
+	config.Source
+	overrides []config.Source
+}
+
+func withOverrides(source config.Source, overrides []config.Source) config.Source {
+	if len(overrides) == 0 {
+		return source
+	}
+
+	return &overrideSource{
+		Source:    source,
+		overrides: overrides,
+	}
+}
+
+func (s *overrideSource) Watch() (config.Watcher, error) {
+	w, err := s.Source.Watch()
+	if err != nil {
+		return nil, err
+	}
+
+	return &overrideWatcher{
+		Watcher:   w,
+		overrides: s.overrides,
+	}, nil
+}
+
+type overrideWatcher struct {
+	config.Watcher
+	overrides []config.Source
+}
+
+func (w *overrideWatcher) Next() ([]*config.KeyValue, error) {
+	kvs, err := w.Watcher.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, override := range w.overrides {
+		values, err := override.Load()
+		if err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, values...)
+	}
+	return kvs, nil
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	envTagName     = "env"
+	defaultTagName = "default"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// walkTags visits every field of t which has the tag, path is made of the json names of the fields
+func walkTags(t reflect.Type, tag string, fn func(path []string, field reflect.StructField, value string) error) error {
+	return walkFields(t, nil, map[reflect.Type]bool{}, func(path []string, field reflect.StructField) error {
+		value, ok := field.Tag.Lookup(tag)
+		if !ok {
+			return nil
+		}
+		return fn(path, field, value)
+	})
+}
+
+func walkFields(t reflect.Type, prefix []string, visiting map[reflect.Type]bool, fn func(path []string, field reflect.StructField) error) error {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return nil
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if promoted(field) {
+			if err := walkFields(field.Type, prefix, visiting, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := fieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		path := append(append([]string{}, prefix...), name)
+		if err := fn(path, field); err != nil {
+			return err
+		}
+
+		if err := walkFields(field.Type, path, visiting, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldName returns the json name of the field, which is also the key in config
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// promoted reports whether the fields of the embedded struct are promoted into the parent like encoding/json,
+// eg: the fields of conf.Bootstrap embedded in the Bootstrap of the service
+func promoted(field reflect.StructField) bool {
+	if !field.Anonymous || strings.Split(field.Tag.Get("json"), ",")[0] != "" {
+		return false
+	}
+
+	// encoding/json ignores the embedded pointers of unexported struct types
+	if !field.IsExported() && field.Type.Kind() == reflect.Ptr {
+		return false
+	}
+	return indirectType(field.Type).Kind() == reflect.Struct
+}
+
+// lookupType returns the type of the field according to the path
+func lookupType(t reflect.Type, path []string) (reflect.Type, bool) {
+	for _, name := range path {
+		t = indirectType(t)
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+
+		var found bool
+		if t, found = lookupField(t, name); !found {
+			return nil, false
+		}
+	}
+	return t, true
+}
+
+// lookupField returns the type of the field named name, the fields of t take precedence over the promoted fields
+func lookupField(t reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && !promoted(field) && fieldName(field) == name {
+			return field.Type, true
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !promoted(field) {
+			continue
+		}
+
+		if out, ok := lookupField(indirectType(field.Type), name); ok {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+// hasPath reports whether the struct of the path is not nil in v
+func hasPath(v reflect.Value, path []string) bool {
+	for _, name := range path {
+		if v = indirectValue(v); !v.IsValid() || v.Kind() != reflect.Struct {
+			return false
+		}
+
+		var found bool
+		if v, found = lookupFieldValue(v, name); !found {
+			return false
+		}
+	}
+	return indirectValue(v).IsValid()
+}
+
+// lookupFieldValue is the same as lookupField, the nil promoted structs are skipped
+func lookupFieldValue(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && !promoted(field) && fieldName(field) == name {
+			return v.Field(i), true
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if !promoted(t.Field(i)) {
+			continue
+		}
+
+		embedded := indirectValue(v.Field(i))
+		if !embedded.IsValid() {
+			continue
+		}
+
+		if out, ok := lookupFieldValue(embedded, name); ok {
+			return out, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// indirectValue returns the value which v points to, the zero Value is returned for nil pointers
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// convertValue converts the raw string into the value which can be unmarshalled into t
+func convertValue(t reflect.Type, raw string) (interface{}, error) {
+	// 时长必须带单位，例如 8h、500ms，不带单位的数字容易被误认为秒
+	if t == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q, the unit is required, eg: 8h", raw)
+		}
+		return int64(d), nil
+	}
+
+	t = indirectType(t)
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(raw, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(raw, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(raw, 64)
+	case reflect.Slice:
+		if strings.HasPrefix(strings.TrimSpace(raw), "[") {
+			break
+		}
+
+		var (
+			splits = strings.Split(raw, ",")
+			out    = make([]interface{}, 0, len(splits))
+		)
+		for _, split := range splits {
+			value, err := convertValue(t.Elem(), strings.TrimSpace(split))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	}
+
+	var out interface{}
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return nil, fmt.Errorf("unsupported value %q for type %v", raw, t)
+	}
+	return out, nil
+}
+
+// setPath sets the value into the nested map according to the path
+func setPath(m map[string]interface{}, path []string, value interface{}) {
+	for i, key := range path {
+		if i == len(path)-1 {
+			m[key] = value
+			return
+		}
+
+		sub, ok := m[key].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			m[key] = sub
+		}
+		m = sub
+	}
+}
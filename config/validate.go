@@ -0,0 +1,254 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var structValidator = newStructValidator()
+
+func newStructValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(fieldName)
+	return v
+}
+
+// FieldError is an invalid field of the config
+type FieldError struct {
+	Path   string
+	Reason string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Reason)
+}
+
+// ValidationError aggregates all the invalid fields of the config
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	values := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		values = append(values, field.Error())
+	}
+	return fmt.Sprintf("invalid config: %s", strings.Join(values, "; "))
+}
+
+type (
+	// protoc-gen-validate generated messages
+	validatorAll interface {
+		ValidateAll() error
+	}
+
+	validatorOne interface {
+		Validate() error
+	}
+
+	multiError interface {
+		AllErrors() []error
+	}
+
+	fieldError interface {
+		Field() string
+		Reason() string
+		Cause() error
+	}
+)
+
+// Validate 校验配置：proto 消息调用 protoc-gen-validate 生成的 ValidateAll/Validate，
+// 普通结构体使用 `validate:"required"` 标签校验，返回所有不合法的字段路径
+func Validate(v interface{}) error {
+	var fields []*FieldError
+	collectMessageErrors(reflect.ValueOf(v), "", map[uintptr]struct{}{}, &fields)
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Struct {
+		err := structValidator.Struct(v)
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			for _, ve := range validationErrors {
+				fields = append(fields, &FieldError{
+					Path:   fieldPath(rv.Type(), ve.StructNamespace(), ve.Namespace()),
+					Reason: fmt.Sprintf("failed on the '%s' rule", ve.Tag()),
+				})
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// collectMessageErrors walks the value and validates the messages generated by protoc-gen-validate
+func collectMessageErrors(v reflect.Value, path string, visited map[uintptr]struct{}, fields *[]*FieldError) {
+	if !v.IsValid() {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+
+		if v.Kind() == reflect.Ptr {
+			if _, ok := visited[v.Pointer()]; ok {
+				return
+			}
+			visited[v.Pointer()] = struct{}{}
+		}
+
+		if v.CanInterface() {
+			switch m := v.Interface().(type) {
+			case validatorAll:
+				appendMessageError(m.ValidateAll(), path, fields)
+				return
+			case validatorOne:
+				appendMessageError(m.Validate(), path, fields)
+				return
+			}
+		}
+		collectMessageErrors(v.Elem(), path, visited, fields)
+	case reflect.Struct:
+		if v.CanAddr() && v.Addr().CanInterface() {
+			switch m := v.Addr().Interface().(type) {
+			case validatorAll:
+				appendMessageError(m.ValidateAll(), path, fields)
+				return
+			case validatorOne:
+				appendMessageError(m.Validate(), path, fields)
+				return
+			}
+		}
+
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if promoted(field) {
+				collectMessageErrors(v.Field(i), path, visited, fields)
+				continue
+			}
+
+			if !field.IsExported() {
+				continue
+			}
+
+			name := fieldName(field)
+			if name == "-" {
+				continue
+			}
+			collectMessageErrors(v.Field(i), joinPath(path, name), visited, fields)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collectMessageErrors(v.Index(i), fmt.Sprintf("%s[%d]", path, i), visited, fields)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			collectMessageErrors(iter.Value(), joinPath(path, fmt.Sprint(iter.Key().Interface())), visited, fields)
+		}
+	}
+}
+
+func appendMessageError(err error, path string, fields *[]*FieldError) {
+	if err == nil {
+		return
+	}
+
+	var multi multiError
+	if errors.As(err, &multi) {
+		for _, e := range multi.AllErrors() {
+			appendMessageError(e, path, fields)
+		}
+		return
+	}
+
+	var fe fieldError
+	if errors.As(err, &fe) {
+		fieldPath := joinPath(path, fe.Field())
+		if fe.Cause() != nil {
+			var (
+				causeMulti multiError
+				causeField fieldError
+			)
+			if errors.As(fe.Cause(), &causeMulti) || errors.As(fe.Cause(), &causeField) {
+				appendMessageError(fe.Cause(), fieldPath, fields)
+				return
+			}
+		}
+
+		*fields = append(*fields, &FieldError{
+			Path:   fieldPath,
+			Reason: fe.Reason(),
+		})
+		return
+	}
+
+	*fields = append(*fields, &FieldError{
+		Path:   path,
+		Reason: err.Error(),
+	})
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// fieldPath converts the validator struct namespace into the path of the json names,
+// the fields of the promoted embedded structs have no segment of their own
+func fieldPath(t reflect.Type, structNamespace, namespace string) string {
+	segments := strings.Split(structNamespace, ".")
+	path := make([]string, 0, len(segments))
+	for _, segment := range segments[1:] {
+		name, index := segment, ""
+		if i := strings.Index(segment, "["); i >= 0 {
+			name, index = segment[:i], segment[i:]
+		}
+
+		t = indirectType(t)
+		if t.Kind() != reflect.Struct {
+			return trimRoot(namespace)
+		}
+
+		field, ok := t.FieldByName(name)
+		if !ok {
+			return trimRoot(namespace)
+		}
+
+		t = field.Type
+		for i := 0; i < strings.Count(index, "["); i++ {
+			t = indirectType(t).Elem()
+		}
+
+		if !promoted(field) {
+			path = append(path, fieldName(field)+index)
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+// trimRoot removes the struct name of the validator namespace
+func trimRoot(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// Subscription holds the latest decoded value of a key, it is safe for concurrent readers
+type Subscription[T any] struct {
+	key     string
+	current atomic.Pointer[T]
+	fn      func(old, new T)
+	mu      sync.Mutex
+}
+
+// Current returns the latest valid value
+func (s *Subscription[T]) Current() T {
+	return *s.current.Load()
+}
+
+func (s *Subscription[T]) onChange(_ string, value config.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, err := decodeValue[T](value)
+	if err != nil {
+		log.Errorf("config key %s changed but invalid, keep the previous value: %v", s.key, err)
+		return
+	}
+
+	old := s.Current()
+	if reflect.DeepEqual(old, next) {
+		return
+	}
+
+	s.current.Store(&next)
+	if s.fn != nil {
+		s.fn(old, next)
+	}
+}
+
+// Watch 将 key 对应的配置解析为 T 并在变更时回调，只有解析、校验通过且内容发生变化时才会调用 fn
+// 同一个 key 可以有多个订阅；注意与 WithWatchers 监听同一个 key 时会互相覆盖
+//
+//	sub, err := config.Watch(c, "data.redis", func(old, new *iredis.Config) {...})
+//	sub.Current()
+func Watch[T any](c config.Config, key string, fn func(old, new T)) (*Subscription[T], error) {
+	value := c.Value(key)
+	if value.Load() == nil {
+		return nil, config.ErrNotFound
+	}
+
+	current, err := decodeValue[T](value)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscription[T]{
+		key: key,
+		fn:  fn,
+	}
+	s.current.Store(&current)
+
+	if err = subscribe(c, key, s.onChange); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func decodeValue[T any](value config.Value) (T, error) {
+	var out T
+	if _, err := scanWithDefaults(&out, value.Scan); err != nil {
+		return out, err
+	}
+	return out, Validate(out)
+}
+
+type subscriberKey struct {
+	c   config.Config
+	key string
+}
+
+var (
+	subscribersMux sync.Mutex
+	subscribers    = map[subscriberKey][]config.Observer{}
+)
+
+// subscribe registers the observer, kratos only keeps one observer per key, so the observers are dispatched here
+func subscribe(c config.Config, key string, o config.Observer) error {
+	subscribersMux.Lock()
+	defer subscribersMux.Unlock()
+
+	sk := subscriberKey{c: c, key: key}
+	if _, ok := subscribers[sk]; !ok {
+		err := c.Watch(key, func(key string, value config.Value) {
+			subscribersMux.Lock()
+			observers := append([]config.Observer{}, subscribers[sk]...)
+			subscribersMux.Unlock()
+
+			for _, observer := range observers {
+				observer(key, value)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	subscribers[sk] = append(subscribers[sk], o)
+	return nil
+}
@@ -0,0 +1,39 @@
+package msgpack
+
+import (
+	"reflect"
+
+	"github.com/go-kratos/kratos/v2/encoding" // nolint
+	"github.com/ugorji/go/codec"
+)
+
+// Name is the name registered for the msgpack codec.
+const Name = "msgpack"
+
+// handle 结构体字段名优先使用 codec tag，其次是 json tag，与 json 的输出保持一致
+var handle = &codec.MsgpackHandle{
+	WriteExt: true,
+}
+
+func init() {
+	handle.RawToString = true
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	encoding.RegisterCodec(msgpackCodec{})
+}
+
+// msgpackCodec is a Codec implementation with msgpack.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var out []byte
+	err := codec.NewEncoderBytes(&out, handle).Encode(v)
+	return out, err
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, handle).Decode(v)
+}
+
+func (msgpackCodec) Name() string {
+	return Name
+}
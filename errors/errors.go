@@ -11,12 +11,16 @@ var (
 	ErrLogin               = errors.New(401, "UNAUTHORIZED", "not auth")
 	ErrUserOperation       = errors.New(402, "USER_OPERATION", "try again later")
 	ErrOutOfStock          = errors.New(403, "OUT_OF_STOCK", "out of stock")
+	ErrForbidden           = errors.New(403, "FORBIDDEN", "permission denied")
 	ErrResourceNotFound    = errors.New(404, "RESOURCE_NOT_FOUND", "resource not found")
 	ErrPriceChanged        = errors.New(405, "PRICE_CHANGED", "price changed")
 	ErrSellOut             = errors.New(406, "SELL_OUT", "sell out")
 	ErrGoodsOff            = errors.New(407, "GOODS_OFF", "goods off")
 	ErrBuyLimit            = errors.New(408, "BUY_LIMIT", "buy limit")
 	ErrNotSupportDeliver   = errors.New(409, "NOT_SUPPORT_DELIVER", "address does not support delivery")
+	ErrRequestInProgress   = errors.New(409, "REQUEST_IN_PROGRESS", "request is being processed")
+	ErrIdempotentMismatch  = errors.New(422, "IDEMPOTENT_MISMATCH", "idempotency key is used by another request")
+	ErrUpgradeRequired     = errors.New(426, "UPGRADE_REQUIRED", "please upgrade the app")
 	ErrToManyRequests      = errors.New(429, "TOO_MANY_REQUEST", "too many request")
 	ErrInternalServer      = errors.New(500, "INTERNAL_SERVER_ERROR", "internal server err")
 )
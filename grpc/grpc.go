@@ -5,13 +5,16 @@ import (
 	"os"
 	"time"
 
+	"github.com/airunny/wiki-go-tools/icontext"
 	k8s "github.com/airunny/wiki-go-tools/kubernetes"
 	mmd "github.com/airunny/wiki-go-tools/metadata"
 	"github.com/airunny/wiki-go-tools/registry"
 	"github.com/go-kratos/kratos/v2/log" // nolint
+	"github.com/go-kratos/kratos/v2/middleware"
 	"github.com/go-kratos/kratos/v2/middleware/recovery"
 	"github.com/go-kratos/kratos/v2/middleware/tracing"
 	"github.com/go-kratos/kratos/v2/middleware/validate"
+	"github.com/go-kratos/kratos/v2/selector"
 	kratosGrpc "github.com/go-kratos/kratos/v2/transport/grpc"
 	"google.golang.org/grpc"
 )
@@ -22,8 +25,9 @@ const (
 )
 
 type Option struct {
-	timeout time.Duration
-	logger  log.Logger
+	timeout     time.Duration
+	logger      log.Logger
+	nodeFilters []selector.NodeFilter
 }
 
 func newOption() *Option {
@@ -35,6 +39,18 @@ func newOption() *Option {
 
 type ClientOption func(o *Option)
 
+// WithNodeFilter 客户端实例过滤，例如 registry.MustParseSelector("version=v3.*").NodeFilter()
+func WithNodeFilter(filters ...selector.NodeFilter) ClientOption {
+	return func(o *Option) {
+		o.nodeFilters = append(o.nodeFilters, filters...)
+	}
+}
+
+// WithCanary 根据 Route-Wsc-Val 将请求路由到对应的灰度实例，没有匹配的实例时使用稳定实例
+func WithCanary(opts ...registry.CanaryOption) ClientOption {
+	return WithNodeFilter(registry.CanaryNodeFilter(opts...))
+}
+
 func WithTimeout(timeout time.Duration) ClientOption {
 	return func(o *Option) {
 		o.timeout = timeout
@@ -61,9 +77,14 @@ func DialInsecureWithShort(ctx context.Context, endpoint string, opts ...ClientO
 			validate.Validator(),
 			tracing.Client(),
 			mmd.Client(),
+			icontext.Client(),
 		),
 	}
 
+	if len(o.nodeFilters) > 0 {
+		clientOpts = append(clientOpts, kratosGrpc.WithNodeFilter(o.nodeFilters...))
+	}
+
 	_, ok := os.LookupEnv("KUBERNETES_SERVICE_HOST")
 	if ok && os.Getenv("REGISTRY") != "NO" {
 		clientSet, err := k8s.NewClient()
@@ -75,6 +96,15 @@ func DialInsecureWithShort(ctx context.Context, endpoint string, opts ...ClientO
 		reg.Start()
 
 		clientOpts = append(clientOpts, kratosGrpc.WithDiscovery(reg))
+	} else {
+		discovery, err := registry.NewLocalDiscovery(o.logger)
+		if err != nil {
+			panic(err)
+		}
+
+		if discovery != nil {
+			clientOpts = append(clientOpts, kratosGrpc.WithDiscovery(discovery))
+		}
 	}
 
 	conn, err := kratosGrpc.DialInsecure(ctx, clientOpts...)
@@ -84,7 +114,18 @@ func DialInsecureWithShort(ctx context.Context, endpoint string, opts ...ClientO
 	return conn
 }
 
+// WithMiddleware 同 kratosGrpc.WithMiddleware，最后加上 icontext.Client() 传递请求上下文；
+// kratosGrpc.WithMiddleware 会覆盖之前设置的中间件，DialInsecure 自定义中间件时使用该方法
+//
+//	grpc.DialInsecure(ctx, logger, grpc.WithMiddleware(recovery.Recovery(), tracing.Client()))
+func WithMiddleware(m ...middleware.Middleware) kratosGrpc.ClientOption {
+	return kratosGrpc.WithMiddleware(append(append([]middleware.Middleware{}, m...), icontext.Client())...)
+}
+
+// DialInsecure 默认的中间件为 icontext.Client()，自定义中间件使用 WithMiddleware，直接使用 kratosGrpc.WithMiddleware 时不会传递请求上下文
 func DialInsecure(ctx context.Context, logger log.Logger, opts ...kratosGrpc.ClientOption) (*grpc.ClientConn, error) {
+	opts = append([]kratosGrpc.ClientOption{WithMiddleware()}, opts...)
+
 	_, ok := os.LookupEnv("KUBERNETES_SERVICE_HOST")
 	if ok && os.Getenv("REGISTRY") != "NO" {
 		clientSet, err := k8s.NewClient()
@@ -96,6 +137,15 @@ func DialInsecure(ctx context.Context, logger log.Logger, opts ...kratosGrpc.Cli
 		reg.Start()
 
 		opts = append(opts, kratosGrpc.WithDiscovery(reg))
+	} else {
+		discovery, err := registry.NewLocalDiscovery(logger)
+		if err != nil {
+			return nil, err
+		}
+
+		if discovery != nil {
+			opts = append(opts, kratosGrpc.WithDiscovery(discovery))
+		}
 	}
 
 	return kratosGrpc.DialInsecure(ctx, opts...)
@@ -5,13 +5,16 @@ import (
 	"os"
 	"time"
 
+	"github.com/airunny/wiki-go-tools/icontext"
 	k8s "github.com/airunny/wiki-go-tools/kubernetes"
 	mmd "github.com/airunny/wiki-go-tools/metadata"
 	"github.com/airunny/wiki-go-tools/registry"
 	"github.com/go-kratos/kratos/v2/log" // nolint
+	"github.com/go-kratos/kratos/v2/middleware"
 	"github.com/go-kratos/kratos/v2/middleware/recovery"
 	"github.com/go-kratos/kratos/v2/middleware/tracing"
 	"github.com/go-kratos/kratos/v2/middleware/validate"
+	"github.com/go-kratos/kratos/v2/selector"
 	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
 )
 
@@ -21,8 +24,9 @@ const (
 )
 
 type Option struct {
-	timeout time.Duration
-	logger  log.Logger
+	timeout     time.Duration
+	logger      log.Logger
+	nodeFilters []selector.NodeFilter
 }
 
 func newOption() *Option {
@@ -34,6 +38,18 @@ func newOption() *Option {
 
 type ClientOption func(o *Option)
 
+// WithNodeFilter 客户端实例过滤，例如 registry.MustParseSelector("version=v3.*").NodeFilter()
+func WithNodeFilter(filters ...selector.NodeFilter) ClientOption {
+	return func(o *Option) {
+		o.nodeFilters = append(o.nodeFilters, filters...)
+	}
+}
+
+// WithCanary 根据 Route-Wsc-Val 将请求路由到对应的灰度实例，没有匹配的实例时使用稳定实例
+func WithCanary(opts ...registry.CanaryOption) ClientOption {
+	return WithNodeFilter(registry.CanaryNodeFilter(opts...))
+}
+
 func WithTimeout(timeout time.Duration) ClientOption {
 	return func(o *Option) {
 		o.timeout = timeout
@@ -60,9 +76,14 @@ func NewClientWithShort(ctx context.Context, endpoint string, opts ...ClientOpti
 			validate.Validator(),
 			tracing.Client(),
 			mmd.Client(),
+			icontext.Client(),
 		),
 	}
 
+	if len(o.nodeFilters) > 0 {
+		clientOpts = append(clientOpts, kratosHttp.WithNodeFilter(o.nodeFilters...))
+	}
+
 	_, ok := os.LookupEnv("KUBERNETES_SERVICE_HOST")
 	if ok && os.Getenv("REGISTRY") != "NO" {
 		clientSet, err := k8s.NewClient()
@@ -74,6 +95,15 @@ func NewClientWithShort(ctx context.Context, endpoint string, opts ...ClientOpti
 		reg.Start()
 
 		clientOpts = append(clientOpts, kratosHttp.WithDiscovery(reg))
+	} else {
+		discovery, err := registry.NewLocalDiscovery(o.logger)
+		if err != nil {
+			panic(err)
+		}
+
+		if discovery != nil {
+			clientOpts = append(clientOpts, kratosHttp.WithDiscovery(discovery))
+		}
 	}
 
 	client, err := kratosHttp.NewClient(ctx, clientOpts...)
@@ -83,7 +113,18 @@ func NewClientWithShort(ctx context.Context, endpoint string, opts ...ClientOpti
 	return client
 }
 
+// WithMiddleware 同 kratosHttp.WithMiddleware，最后加上 icontext.Client() 传递请求上下文；
+// kratosHttp.WithMiddleware 会覆盖之前设置的中间件，NewClient 自定义中间件时使用该方法
+//
+//	http.NewClient(ctx, logger, http.WithMiddleware(recovery.Recovery(), tracing.Client()))
+func WithMiddleware(m ...middleware.Middleware) kratosHttp.ClientOption {
+	return kratosHttp.WithMiddleware(append(append([]middleware.Middleware{}, m...), icontext.Client())...)
+}
+
+// NewClient 默认的中间件为 icontext.Client()，自定义中间件使用 WithMiddleware，直接使用 kratosHttp.WithMiddleware 时不会传递请求上下文
 func NewClient(ctx context.Context, logger log.Logger, opts ...kratosHttp.ClientOption) (*kratosHttp.Client, error) {
+	opts = append([]kratosHttp.ClientOption{WithMiddleware()}, opts...)
+
 	_, ok := os.LookupEnv("KUBERNETES_SERVICE_HOST")
 	if ok && os.Getenv("REGISTRY") != "NO" {
 		clientSet, err := k8s.NewClient()
@@ -95,6 +136,15 @@ func NewClient(ctx context.Context, logger log.Logger, opts ...kratosHttp.Client
 		reg.Start()
 
 		opts = append(opts, kratosHttp.WithDiscovery(reg))
+	} else {
+		discovery, err := registry.NewLocalDiscovery(logger)
+		if err != nil {
+			return nil, err
+		}
+
+		if discovery != nil {
+			opts = append(opts, kratosHttp.WithDiscovery(discovery))
+		}
 	}
 
 	return kratosHttp.NewClient(ctx, opts...)
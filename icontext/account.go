@@ -0,0 +1,20 @@
+package icontext
+
+import (
+	"context"
+
+	"github.com/airunny/wiki-go-tools/token"
+)
+
+type accountKey struct{}
+
+// WithAccount 保存 token 中解析出的账号，同时设置用户ID
+func WithAccount(ctx context.Context, account *token.Account) context.Context {
+	ctx = context.WithValue(ctx, accountKey{}, account)
+	return WithUserId(ctx, account.ID)
+}
+
+func AccountFrom(ctx context.Context) (*token.Account, bool) {
+	account, ok := ctx.Value(accountKey{}).(*token.Account)
+	return account, ok && account != nil
+}
@@ -8,6 +8,7 @@ import (
 )
 
 var (
+	// appIdMapping 内置的 app id 映射，新的 app id 通过 SetProjects 或 config.WatchProjects 配置
 	appIdMapping = map[string]string{
 		"1":   "fxeye",
 		"2":   "wikifx",
@@ -37,6 +38,10 @@ var (
 )
 
 func fromBasicData(ctx context.Context, index int) (string, bool) {
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return info.basicField(index)
+	}
+
 	basicData, ok := BasicDataFrom(ctx)
 	if !ok {
 		return "", false
@@ -53,49 +58,63 @@ func fromBasicData(ctx context.Context, index int) (string, bool) {
 // device id
 
 func DeviceIdFrom(ctx context.Context) (string, bool) {
-	return fromBasicData(ctx, 5)
+	return fromBasicData(ctx, basicDataDeviceIdIndex)
 }
 
 // app版本
 
 func AppVersionFrom(ctx context.Context) (string, bool) {
-	return fromBasicData(ctx, 3)
+	return fromBasicData(ctx, basicDataAppVersionIndex)
 }
 
 // app 平台
 
 func PlatformFrom(ctx context.Context) (Platform, bool) {
-	plat, ok := fromBasicData(ctx, 0)
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return info.platform()
+	}
+
+	plat, ok := fromBasicData(ctx, basicDataPlatformIndex)
 	if !ok {
 		return "", false
 	}
+	return parsePlatform(plat), true
+}
 
+func parsePlatform(plat string) Platform {
 	switch plat {
 	case "0":
-		return IOS, true
+		return IOS
 	case "1":
-		return Android, true
+		return Android
 	case "3":
-		return PC, true
+		return PC
 	case "999":
-		return Web, true
+		return Web
 	}
-	return Platform(plat), true
+	return Platform(plat)
 }
 
 // appid
 
 func AppIdFrom(ctx context.Context) (string, bool) {
-	return fromBasicData(ctx, 2)
+	return fromBasicData(ctx, basicDataAppIdIndex)
 }
 
 func ProjectFrom(ctx context.Context) string {
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return info.Project
+	}
+
 	appId, ok := AppIdFrom(ctx)
 	if !ok {
 		return ""
 	}
 
-	return appIdMapping[appId]
+	if info, ok := LookupProject(appId); ok {
+		return info.Project
+	}
+	return ""
 }
 
 // area code
@@ -110,27 +129,13 @@ func AreaCodeFrom(ctx context.Context) (string, bool) {
 }
 
 func AllLanguageCodeFrom(ctx context.Context) []string {
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return append([]string{}, info.Languages...)
+	}
+
 	var (
 		languageCode, _          = LanguageCodeFrom(ctx)
 		preferredLanguageCode, _ = PreferredLanguageCodeFrom(ctx)
 	)
-
-	var (
-		languages = strings.Split(preferredLanguageCode, ",")
-		out       = make([]string, 0, len(languages)+1)
-	)
-
-	for _, language := range languages {
-		language = strings.ToLower(strings.TrimSpace(language))
-		if language == "" {
-			continue
-		}
-
-		if language == strings.ToLower(languageCode) {
-			continue
-		}
-		out = append(out, language)
-	}
-	out = append(out, strings.ToLower(languageCode))
-	return out
+	return allLanguageCodes(languageCode, preferredLanguageCode)
 }
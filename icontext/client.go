@@ -0,0 +1,105 @@
+package icontext
+
+import (
+	"context"
+	"strings"
+
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+)
+
+// clientHeaders icontext 中的字段 => 下游服务使用的请求头
+var clientHeaders = []struct {
+	key    string
+	header string
+}{
+	{key: clientIP, header: iheader.ForwardForHeaderKey},
+	{key: userIdKey, header: iheader.UserIdHeaderKey},
+	{key: basicDataKey, header: iheader.BasicDataHeaderKey},
+	{key: countryCodeKey, header: iheader.CountryCodeHeaderKey},
+	{key: languageCodeKey, header: iheader.LanguageCodeHeaderKey},
+	{key: preferredLanguageCodeKey, header: iheader.PreferredLanguageHeaderKey},
+	{key: requestIdKey, header: iheader.RequestIdKey},
+	{key: wikiDataCenterRequestIdKey, header: iheader.RequestIdKeyOld},
+	{key: sceneCodeKey, header: iheader.SceneCodeKey},
+	{key: wscKey, header: iheader.WSCKey},
+	{key: xPWX, header: iheader.XPWA},
+}
+
+type clientOptions struct {
+	allows map[string]struct{}
+}
+
+type ClientOption func(*clientOptions)
+
+// WithAllowHeaders 只传递这些请求头，默认传递所有字段，例如 iheader.UserIdHeaderKey、iheader.LanguageCodeHeaderKey
+func WithAllowHeaders(headers ...string) ClientOption {
+	return func(o *clientOptions) {
+		o.allows = make(map[string]struct{}, len(headers))
+		for _, header := range headers {
+			o.allows[strings.ToLower(header)] = struct{}{}
+		}
+	}
+}
+
+type skipPropagationKey struct{}
+
+// SkipPropagation 本次调用不传递这些请求头，不指定请求头时不传递任何字段
+//
+//	reply, err := client.GetUser(icontext.SkipPropagation(ctx, iheader.UserIdHeaderKey), req)
+func SkipPropagation(ctx context.Context, headers ...string) context.Context {
+	skips := make(map[string]struct{}, len(headers))
+	for _, header := range headers {
+		skips[strings.ToLower(header)] = struct{}{}
+	}
+	return context.WithValue(ctx, skipPropagationKey{}, skips)
+}
+
+// Client 客户端中间件，将 TryParseHeader 解析到 context 中的字段以 iheader 中的请求头传递给下游服务，
+// 调用方已经设置的请求头不会被覆盖
+//
+//	conn, err := grpc.DialInsecure(ctx, grpc.WithMiddleware(icontext.Client()))
+func Client(opts ...ClientOption) middleware.Middleware {
+	o := &clientOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromClientContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			skips, skip := ctx.Value(skipPropagationKey{}).(map[string]struct{})
+			if skip && len(skips) == 0 {
+				return handler(ctx, req)
+			}
+
+			header := tr.RequestHeader()
+			for _, item := range clientHeaders {
+				name := strings.ToLower(item.header)
+				if _, ok := skips[name]; ok {
+					continue
+				}
+
+				if o.allows != nil {
+					if _, ok := o.allows[name]; !ok {
+						continue
+					}
+				}
+
+				if header.Get(item.header) != "" {
+					continue
+				}
+
+				if value, ok := fromValue(ctx, item.key); ok {
+					header.Set(item.header, value)
+				}
+			}
+			return handler(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package icontext
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+type testHeader http.Header
+
+func (h testHeader) Get(key string) string      { return http.Header(h).Get(key) }
+func (h testHeader) Set(key, value string)      { http.Header(h).Set(key, value) }
+func (h testHeader) Add(key, value string)      { http.Header(h).Add(key, value) }
+func (h testHeader) Keys() []string             { return nil }
+func (h testHeader) Values(key string) []string { return http.Header(h).Values(key) }
+
+type testTransport struct {
+	header testHeader
+}
+
+func (t *testTransport) Kind() transport.Kind            { return transport.KindHTTP }
+func (t *testTransport) Endpoint() string                { return "" }
+func (t *testTransport) Operation() string               { return "" }
+func (t *testTransport) RequestHeader() transport.Header { return t.header }
+func (t *testTransport) ReplyHeader() transport.Header   { return testHeader{} }
+
+func TestClient(t *testing.T) {
+	invoke := func(ctx context.Context, opts ...ClientOption) testHeader {
+		tr := &testTransport{header: testHeader{}}
+		tr.header.Set(iheader.CountryCodeHeaderKey, "344")
+		ctx = transport.NewClientContext(ctx, tr)
+		_, err := Client(opts...)(func(context.Context, interface{}) (interface{}, error) {
+			return nil, nil
+		})(ctx, nil)
+		assert.Nil(t, err)
+		return tr.header
+	}
+
+	header := invoke(newTestContext())
+	assert.Equal(t, "user-1", header.Get(iheader.UserIdHeaderKey))
+	assert.Equal(t, "1,0,500,3.2.1,0,device-1", header.Get(iheader.BasicDataHeaderKey))
+	assert.Equal(t, "zh-CN", header.Get(iheader.LanguageCodeHeaderKey))
+	assert.Equal(t, "en, zh-cn", header.Get(iheader.PreferredLanguageHeaderKey))
+	// 调用方设置的请求头不会被覆盖
+	assert.Equal(t, "344", header.Get(iheader.CountryCodeHeaderKey))
+
+	header = invoke(newTestContext(), WithAllowHeaders(iheader.UserIdHeaderKey))
+	assert.Equal(t, "user-1", header.Get(iheader.UserIdHeaderKey))
+	assert.Equal(t, "", header.Get(iheader.BasicDataHeaderKey))
+
+	header = invoke(SkipPropagation(newTestContext(), iheader.UserIdHeaderKey))
+	assert.Equal(t, "", header.Get(iheader.UserIdHeaderKey))
+	assert.Equal(t, "zh-CN", header.Get(iheader.LanguageCodeHeaderKey))
+
+	header = invoke(SkipPropagation(newTestContext()))
+	assert.Equal(t, "", header.Get(iheader.UserIdHeaderKey))
+	assert.Equal(t, "", header.Get(iheader.LanguageCodeHeaderKey))
+}
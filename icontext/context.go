@@ -67,7 +67,10 @@ func WSCFrom(ctx context.Context) (string, bool) {
 // basic data
 
 func WithBasicData(ctx context.Context, in string) context.Context {
-	return withValue(ctx, basicDataKey, in)
+	ctx = withValue(ctx, basicDataKey, in)
+	return updateRequestInfo(ctx, func(info *RequestInfo) {
+		info.setBasicData(in)
+	})
 }
 
 func BasicDataFrom(ctx context.Context) (string, bool) {
@@ -87,40 +90,66 @@ func ClientIPFrom(ctx context.Context) (string, bool) {
 // app 用户ID
 
 func WithUserId(ctx context.Context, in string) context.Context {
-	return withValue(ctx, userIdKey, in)
+	ctx = withValue(ctx, userIdKey, in)
+	return updateRequestInfo(ctx, func(info *RequestInfo) {
+		info.UserId = in
+	})
 }
 
 func UserIdFrom(ctx context.Context) (string, bool) {
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return info.UserId, info.UserId != ""
+	}
 	return fromValue(ctx, userIdKey)
 }
 
 // 语言码
 
 func WithLanguageCode(ctx context.Context, in string) context.Context {
-	return withValue(ctx, languageCodeKey, in)
+	ctx = withValue(ctx, languageCodeKey, in)
+	return updateRequestInfo(ctx, func(info *RequestInfo) {
+		info.LanguageCode = in
+		info.Languages = allLanguageCodes(in, info.preferredLanguageCode)
+	})
 }
 
 func LanguageCodeFrom(ctx context.Context) (string, bool) {
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return info.LanguageCode, info.LanguageCode != ""
+	}
 	return fromValue(ctx, languageCodeKey)
 }
 
 // 偏好语言
 
 func WithPreferredLanguageCode(ctx context.Context, in string) context.Context {
-	return withValue(ctx, preferredLanguageCodeKey, in)
+	ctx = withValue(ctx, preferredLanguageCodeKey, in)
+	return updateRequestInfo(ctx, func(info *RequestInfo) {
+		info.preferredLanguageCode = in
+		info.Languages = allLanguageCodes(info.LanguageCode, in)
+	})
 }
 
 func PreferredLanguageCodeFrom(ctx context.Context) (string, bool) {
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return info.preferredLanguageCode, info.preferredLanguageCode != ""
+	}
 	return fromValue(ctx, preferredLanguageCodeKey)
 }
 
 // 城市码
 
 func WithCountryCode(ctx context.Context, in string) context.Context {
-	return withValue(ctx, countryCodeKey, in)
+	ctx = withValue(ctx, countryCodeKey, in)
+	return updateRequestInfo(ctx, func(info *RequestInfo) {
+		info.CountryCode = in
+	})
 }
 
 func CountryCodeFrom(ctx context.Context) (string, bool) {
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return info.CountryCode, info.CountryCode != ""
+	}
 	return fromValue(ctx, countryCodeKey)
 }
 
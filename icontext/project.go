@@ -0,0 +1,63 @@
+package icontext
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ProjectInfo app id 对应的项目信息
+type ProjectInfo struct {
+	AppId           string `json:"app_id"`
+	Project         string `json:"project"`
+	Brand           string `json:"brand"`
+	PlatformFamily  string `json:"platform_family"`  // 平台类型，例如 app、web、pc
+	DefaultLanguage string `json:"default_language"` // 默认语言
+}
+
+var projects atomic.Pointer[map[string]*ProjectInfo]
+
+func init() {
+	SetProjects(nil)
+}
+
+// SetProjects 设置 app id 对应的项目信息，key 为 app id，覆盖内置的映射，未配置的 app id 使用内置的映射；
+// 从配置中加载并热更新见 config.WatchProjects
+func SetProjects(in map[string]*ProjectInfo) {
+	out := make(map[string]*ProjectInfo, len(appIdMapping)+len(in))
+	for appId, project := range appIdMapping {
+		out[appId] = &ProjectInfo{
+			AppId:   appId,
+			Project: project,
+		}
+	}
+
+	for appId, info := range in {
+		if info == nil {
+			continue
+		}
+
+		info := *info
+		info.AppId = appId
+		out[appId] = &info
+	}
+	projects.Store(&out)
+}
+
+// LookupProject returns the project of the app id
+func LookupProject(appId string) (*ProjectInfo, bool) {
+	info, ok := (*projects.Load())[appId]
+	return info, ok
+}
+
+// ProjectInfoFrom 根据请求中的 app id 获取项目信息
+func ProjectInfoFrom(ctx context.Context) (*ProjectInfo, bool) {
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return info.ProjectInfo, info.ProjectInfo != nil
+	}
+
+	appId, ok := AppIdFrom(ctx)
+	if !ok {
+		return nil, false
+	}
+	return LookupProject(appId)
+}
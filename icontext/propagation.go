@@ -0,0 +1,127 @@
+package icontext
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// exportKeys 所有需要跨进程传递的字段
+var exportKeys = []string{
+	wikiDataCenterRequestIdKey,
+	clientIP,
+	basicDataKey,
+	languageCodeKey,
+	countryCodeKey,
+	preferredLanguageCodeKey,
+	clientPort,
+	clientMac,
+	sceneCodeKey,
+	requestIdKey,
+	userIdKey,
+	wscKey,
+	sessionAppIdKey,
+	xPWX,
+}
+
+// traceContext propagates the w3c traceparent, tracestate and baggage
+var traceContext = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Export 导出 context 中的所有字段以及链路追踪信息，用于投递到 kafka、异步任务等，消费端使用 Import 恢复
+func Export(ctx context.Context) map[string]string {
+	out := make(map[string]string, len(exportKeys)+2)
+	for _, key := range exportKeys {
+		if value, ok := fromValue(ctx, key); ok {
+			out[key] = value
+		}
+	}
+
+	traceContext.Inject(ctx, propagation.MapCarrier(out))
+	return out
+}
+
+// Import 将 Export 导出的字段恢复到 ctx 中，key 不区分大小写
+//
+//	go func(values map[string]string) {
+//		ctx := icontext.Import(context.Background(), values)
+//		...
+//	}(icontext.Export(ctx))
+func Import(ctx context.Context, in map[string]string) context.Context {
+	if len(in) == 0 {
+		return ctx
+	}
+
+	values := make(map[string]string, len(in))
+	for key, value := range in {
+		values[strings.ToLower(key)] = value
+	}
+
+	for _, key := range exportKeys {
+		if value, ok := values[strings.ToLower(key)]; ok && value != "" {
+			ctx = withValue(ctx, key, value)
+		}
+	}
+
+	ctx = traceContext.Extract(ctx, propagation.MapCarrier(values))
+	return WithRequestInfo(ctx, ParseRequestInfo(ctx))
+}
+
+// KafkaHeader 与 kafka-go 的 kafka.Header 结构一致，可以直接转换；
+// sarama 使用 sarama.RecordHeader{Key: []byte(h.Key), Value: h.Value}
+type KafkaHeader struct {
+	Key   string
+	Value []byte
+}
+
+// ExportKafkaHeaders 导出 context 中的字段作为 kafka 消息头
+func ExportKafkaHeaders(ctx context.Context) []KafkaHeader {
+	values := Export(ctx)
+	out := make([]KafkaHeader, 0, len(values))
+	for key, value := range values {
+		out = append(out, KafkaHeader{
+			Key:   key,
+			Value: []byte(value),
+		})
+	}
+	return out
+}
+
+// ImportKafkaHeaders 从 kafka 消息头中恢复 context
+func ImportKafkaHeaders(ctx context.Context, headers []KafkaHeader) context.Context {
+	values := make(map[string]string, len(headers))
+	for _, header := range headers {
+		values[header.Key] = string(header.Value)
+	}
+	return Import(ctx, values)
+}
+
+// Envelope 带有 context 的 json 消息，用于不支持消息头的队列以及异步任务
+type Envelope struct {
+	Metadata map[string]string `json:"metadata"`
+	Payload  json.RawMessage   `json:"payload"`
+}
+
+// NewEnvelope 将 payload 以及 context 中的字段打包为 Envelope
+func NewEnvelope(ctx context.Context, payload interface{}) (*Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		Metadata: Export(ctx),
+		Payload:  data,
+	}, nil
+}
+
+// Restore 从 Envelope 中恢复 context
+func (e *Envelope) Restore(ctx context.Context) context.Context {
+	return Import(ctx, e.Metadata)
+}
+
+// Decode 解析 payload
+func (e *Envelope) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
@@ -0,0 +1,56 @@
+package icontext
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExportImport(t *testing.T) {
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(newTestContext(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	ctx = WithRequestId(ctx, "req-1")
+
+	check := func(restored context.Context) {
+		requestId, _ := RequestIdFrom(restored)
+		assert.Equal(t, "req-1", requestId)
+		userId, _ := UserIdFrom(restored)
+		assert.Equal(t, "user-1", userId)
+		languageCode, _ := LanguageCodeFrom(restored)
+		assert.Equal(t, "zh-CN", languageCode)
+		countryCode, _ := CountryCodeFrom(restored)
+		assert.Equal(t, "156", countryCode)
+		assert.Equal(t, "wikistock", ProjectFrom(restored))
+
+		span := trace.SpanContextFromContext(restored)
+		assert.Equal(t, traceId, span.TraceID())
+		assert.Equal(t, spanId, span.SpanID())
+		assert.True(t, span.IsRemote())
+	}
+
+	values := Export(ctx)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", values["traceparent"])
+	check(Import(context.Background(), values))
+	check(ImportKafkaHeaders(context.Background(), ExportKafkaHeaders(ctx)))
+
+	envelope, err := NewEnvelope(ctx, map[string]string{"order_id": "1"})
+	assert.Nil(t, err)
+	data, err := json.Marshal(envelope)
+	assert.Nil(t, err)
+
+	var received Envelope
+	assert.Nil(t, json.Unmarshal(data, &received))
+	check(received.Restore(context.Background()))
+
+	var payload map[string]string
+	assert.Nil(t, received.Decode(&payload))
+	assert.Equal(t, "1", payload["order_id"])
+}
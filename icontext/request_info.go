@@ -0,0 +1,139 @@
+package icontext
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	basicDataPlatformIndex   = 0
+	basicDataAppIdIndex      = 2
+	basicDataAppVersionIndex = 3
+	basicDataDeviceIdIndex   = 5
+)
+
+type requestInfoKey struct{}
+
+// RequestInfo 请求的基础信息，由 TryParseHeader 中间件解析一次后保存在 context 中，
+// DeviceIdFrom、AppVersionFrom、PlatformFrom 等方法优先从这里读取，不再每次解析 metadata 以及 BasicData
+type RequestInfo struct {
+	Platform     Platform
+	AppVersion   string
+	AppId        string
+	DeviceId     string
+	Project      string
+	ProjectInfo  *ProjectInfo // app id 未配置时为 nil
+	LanguageCode string
+	Languages    []string // 偏好语言 + 语言code，见 AllLanguageCodeFrom
+	CountryCode  string
+	UserId       string
+
+	basicData             []string
+	preferredLanguageCode string
+	version               Version
+	versionOK             bool
+}
+
+// ParseRequestInfo 从 context 的 metadata 中解析请求信息
+func ParseRequestInfo(ctx context.Context) *RequestInfo {
+	var (
+		basicData, _             = fromValue(ctx, basicDataKey)
+		languageCode, _          = fromValue(ctx, languageCodeKey)
+		preferredLanguageCode, _ = fromValue(ctx, preferredLanguageCodeKey)
+		countryCode, _           = fromValue(ctx, countryCodeKey)
+		userId, _                = fromValue(ctx, userIdKey)
+	)
+
+	info := &RequestInfo{
+		LanguageCode:          languageCode,
+		CountryCode:           countryCode,
+		UserId:                userId,
+		preferredLanguageCode: preferredLanguageCode,
+	}
+	info.setBasicData(basicData)
+	info.Languages = allLanguageCodes(languageCode, preferredLanguageCode)
+	return info
+}
+
+func (r *RequestInfo) setBasicData(in string) {
+	r.basicData = nil
+	if in != "" {
+		r.basicData = strings.Split(in, ",")
+	}
+
+	r.Platform, _ = r.platform()
+	r.AppId, _ = r.basicField(basicDataAppIdIndex)
+	r.AppVersion, _ = r.basicField(basicDataAppVersionIndex)
+	r.DeviceId, _ = r.basicField(basicDataDeviceIdIndex)
+	r.version, r.versionOK = Version{}, false
+	if appVersion, ok := r.basicField(basicDataAppVersionIndex); ok {
+		v, err := ParseVersion(appVersion)
+		r.version, r.versionOK = v, err == nil
+	}
+	r.Project, r.ProjectInfo = "", nil
+	if info, ok := LookupProject(r.AppId); ok {
+		r.Project, r.ProjectInfo = info.Project, info
+	}
+}
+
+func (r *RequestInfo) basicField(index int) (string, bool) {
+	if index >= len(r.basicData) {
+		return "", false
+	}
+	return r.basicData[index], true
+}
+
+func (r *RequestInfo) platform() (Platform, bool) {
+	plat, ok := r.basicField(basicDataPlatformIndex)
+	if !ok {
+		return "", false
+	}
+	return parsePlatform(plat), true
+}
+
+func (r *RequestInfo) clone() *RequestInfo {
+	out := *r
+	return &out
+}
+
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+func RequestInfoFrom(ctx context.Context) (*RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info, ok && info != nil
+}
+
+// updateRequestInfo keeps the RequestInfo consistent with the metadata after the values are changed
+func updateRequestInfo(ctx context.Context, fn func(info *RequestInfo)) context.Context {
+	info, ok := RequestInfoFrom(ctx)
+	if !ok {
+		return ctx
+	}
+
+	info = info.clone()
+	fn(info)
+	return WithRequestInfo(ctx, info)
+}
+
+func allLanguageCodes(languageCode, preferredLanguageCode string) []string {
+	var (
+		languages = strings.Split(preferredLanguageCode, ",")
+		out       = make([]string, 0, len(languages)+1)
+	)
+
+	for _, language := range languages {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if language == "" {
+			continue
+		}
+
+		if language == strings.ToLower(languageCode) {
+			continue
+		}
+		out = append(out, language)
+	}
+	out = append(out, strings.ToLower(languageCode))
+	return out
+}
@@ -0,0 +1,101 @@
+package icontext
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestContext() context.Context {
+	ctx := context.Background()
+	ctx = WithBasicData(ctx, "1,0,500,3.2.1,0,device-1")
+	ctx = WithUserId(ctx, "user-1")
+	ctx = WithLanguageCode(ctx, "zh-CN")
+	ctx = WithPreferredLanguageCode(ctx, "en, zh-cn")
+	ctx = WithCountryCode(ctx, "156")
+	return ctx
+}
+
+func TestRequestInfo(t *testing.T) {
+	var (
+		ctx  = newTestContext()
+		info = ParseRequestInfo(ctx)
+	)
+
+	assert.Equal(t, Android, info.Platform)
+	assert.Equal(t, "500", info.AppId)
+	assert.Equal(t, "3.2.1", info.AppVersion)
+	assert.Equal(t, "device-1", info.DeviceId)
+	assert.Equal(t, "wikistock", info.Project)
+	assert.Equal(t, []string{"en", "zh-cn"}, info.Languages)
+	assert.Equal(t, "156", info.CountryCode)
+	assert.Equal(t, "user-1", info.UserId)
+
+	// 从 RequestInfo 读取的结果与从 metadata 解析的一致
+	parsed := WithRequestInfo(ctx, info)
+	for _, c := range []context.Context{ctx, parsed} {
+		platform, ok := PlatformFrom(c)
+		assert.True(t, ok)
+		assert.Equal(t, Android, platform)
+		deviceId, _ := DeviceIdFrom(c)
+		assert.Equal(t, "device-1", deviceId)
+		assert.Equal(t, "wikistock", ProjectFrom(c))
+		assert.Equal(t, []string{"en", "zh-cn"}, AllLanguageCodeFrom(c))
+	}
+
+	// 修改之后 RequestInfo 同步更新，原来的 context 不受影响
+	updated := WithUserId(WithBasicData(parsed, "0,0,4"), "user-2")
+	userId, _ := UserIdFrom(updated)
+	assert.Equal(t, "user-2", userId)
+	platform, _ := PlatformFrom(updated)
+	assert.Equal(t, IOS, platform)
+	_, ok := DeviceIdFrom(updated)
+	assert.False(t, ok)
+	assert.Equal(t, "wikibit", ProjectFrom(updated))
+
+	userId, _ = UserIdFrom(parsed)
+	assert.Equal(t, "user-1", userId)
+}
+
+func benchmarkAccessors(b *testing.B, ctx context.Context) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = DeviceIdFrom(ctx)
+		_, _ = AppVersionFrom(ctx)
+		_, _ = PlatformFrom(ctx)
+		_, _ = AppIdFrom(ctx)
+		_ = ProjectFrom(ctx)
+	}
+}
+
+func BenchmarkAccessorsFromMetadata(b *testing.B) {
+	benchmarkAccessors(b, newTestContext())
+}
+
+func BenchmarkAccessorsFromRequestInfo(b *testing.B) {
+	ctx := newTestContext()
+	benchmarkAccessors(b, WithRequestInfo(ctx, ParseRequestInfo(ctx)))
+}
+
+func TestProjects(t *testing.T) {
+	defer SetProjects(nil)
+
+	SetProjects(map[string]*ProjectInfo{
+		"700": {Project: "wikinew", Brand: "WikiNew", PlatformFamily: "app", DefaultLanguage: "en"},
+	})
+
+	ctx := WithBasicData(context.Background(), "1,0,700,1.0.0")
+	info, ok := ProjectInfoFrom(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, &ProjectInfo{AppId: "700", Project: "wikinew", Brand: "WikiNew", PlatformFamily: "app", DefaultLanguage: "en"}, info)
+	assert.Equal(t, "wikinew", ProjectFrom(WithRequestInfo(ctx, ParseRequestInfo(ctx))))
+
+	// 未配置的 app id 使用内置的映射
+	info, ok = LookupProject("500")
+	assert.True(t, ok)
+	assert.Equal(t, "wikistock", info.Project)
+
+	_, ok = ProjectInfoFrom(WithBasicData(context.Background(), "1,0,999"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,316 @@
+package icontext
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Version app 版本，支持 3.12、v3.12.0、V3.12.0、3.12.0.456、3.12.0-beta.1、3.12.0+456、3.12.0(456) 等格式，
+// 第四段数字、+ 以及括号中的数字作为 build 号
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Pre   string // 预发布版本，例如 beta.1，比正式版本小
+	Build int
+}
+
+// ParseVersion parses the app version
+func ParseVersion(in string) (Version, error) {
+	var (
+		v   Version
+		raw = strings.TrimSpace(in)
+	)
+
+	if strings.HasPrefix(raw, "v") || strings.HasPrefix(raw, "V") {
+		raw = raw[1:]
+	}
+
+	if raw == "" {
+		return v, fmt.Errorf("empty version")
+	}
+
+	// build: 3.12.0(456) 3.12.0+456
+	hasBuild := false
+	if i := strings.IndexAny(raw, "(+"); i >= 0 {
+		build := strings.TrimSpace(strings.TrimSuffix(raw[i+1:], ")"))
+		n, err := strconv.Atoi(build)
+		if err != nil {
+			return v, fmt.Errorf("invalid build of version %q", in)
+		}
+		v.Build, raw, hasBuild = n, strings.TrimSpace(raw[:i]), true
+	}
+
+	// pre-release: 3.12.0-beta.1
+	if i := strings.IndexByte(raw, '-'); i >= 0 {
+		v.Pre, raw = raw[i+1:], raw[:i]
+	}
+
+	// 第四段数字和 +、括号中的 build 号不能同时存在，例如 3.12.0.456(789)
+	splits := strings.Split(raw, ".")
+	if len(splits) > 4 || (len(splits) == 4 && hasBuild) {
+		return v, fmt.Errorf("invalid version %q", in)
+	}
+
+	fields := []*int{&v.Major, &v.Minor, &v.Patch, &v.Build}
+	for i, split := range splits {
+		n, err := strconv.Atoi(split)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", in)
+		}
+		*fields[i] = n
+	}
+	return v, nil
+}
+
+func MustParseVersion(in string) Version {
+	v, err := ParseVersion(in)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (v Version) String() string {
+	out := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		out += "-" + v.Pre
+	}
+
+	if v.Build > 0 {
+		out += "+" + strconv.Itoa(v.Build)
+	}
+	return out
+}
+
+// Compare returns -1, 0 or 1, the build number is compared at last
+func (v Version) Compare(o Version) int {
+	for _, pair := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c := compareInt(pair[0], pair[1]); c != 0 {
+			return c
+		}
+	}
+
+	if c := comparePre(v.Pre, o.Pre); c != 0 {
+		return c
+	}
+	return compareInt(v.Build, o.Build)
+}
+
+func (v Version) LessThan(o Version) bool {
+	return v.Compare(o) < 0
+}
+
+func (v Version) AtLeast(o Version) bool {
+	return v.Compare(o) >= 0
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePre compares the pre-release versions, the release version is greater than any pre-release version
+func comparePre(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	var (
+		as = strings.Split(a, ".")
+		bs = strings.Split(b, ".")
+	)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareInt(an, bn)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(as), len(bs))
+}
+
+type versionConstraint struct {
+	op      string
+	version Version
+}
+
+// match ignores the build number like semver, =3.12.0 matches 3.12.0(456)
+func (c versionConstraint) match(v Version) bool {
+	v.Build = 0
+	n := v.Compare(Version{Major: c.version.Major, Minor: c.version.Minor, Patch: c.version.Patch, Pre: c.version.Pre})
+	switch c.op {
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	case "!=":
+		return n != 0
+	}
+	return n == 0
+}
+
+// VersionRange 版本范围，空格或逗号分隔的条件同时满足，|| 分隔的条件满足其一即可，匹配时忽略 build 号
+//
+//	>=3.12.0 <4.0.0
+//	<3.0.0 || >=3.12.0
+type VersionRange [][]versionConstraint
+
+func ParseVersionRange(in string) (VersionRange, error) {
+	var out VersionRange
+	for _, group := range strings.Split(in, "||") {
+		var (
+			constraints []versionConstraint
+			items       = strings.FieldsFunc(group, func(r rune) bool { return r == ' ' || r == ',' })
+		)
+		for i := 0; i < len(items); i++ {
+			item, op := items[i], "="
+			for _, prefix := range []string{">=", "<=", "!=", ">", "<", "="} {
+				if strings.HasPrefix(item, prefix) {
+					op, item = prefix, strings.TrimPrefix(item, prefix)
+					break
+				}
+			}
+
+			// 操作符后面有空格，例如 >= 3.12.0
+			if item == "" && i+1 < len(items) {
+				i++
+				item = items[i]
+			}
+
+			v, err := ParseVersion(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version range %q: %w", in, err)
+			}
+			constraints = append(constraints, versionConstraint{op: op, version: v})
+		}
+
+		if len(constraints) == 0 {
+			return nil, fmt.Errorf("invalid version range %q", in)
+		}
+		out = append(out, constraints)
+	}
+	return out, nil
+}
+
+func MustParseVersionRange(in string) VersionRange {
+	r, err := ParseVersionRange(in)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r VersionRange) Match(v Version) bool {
+	for _, group := range r {
+		matched := true
+		for _, c := range group {
+			if !c.match(v) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// VersionGate 各个平台的最低版本，可以在配置变更时调用 Update 更新
+type VersionGate struct {
+	mins atomic.Pointer[map[Platform]Version]
+}
+
+// NewVersionGate 参数为平台 => 最低版本，例如 {"iOS": "3.12.0", "Android": "3.10.0"}
+func NewVersionGate(mins map[string]string) (*VersionGate, error) {
+	g := &VersionGate{}
+	if err := g.Update(mins); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *VersionGate) Update(mins map[string]string) error {
+	out := make(map[Platform]Version, len(mins))
+	for platform, raw := range mins {
+		v, err := ParseVersion(raw)
+		if err != nil {
+			return fmt.Errorf("invalid min version of %s: %w", platform, err)
+		}
+		out[Platform(platform)] = v
+	}
+	g.mins.Store(&out)
+	return nil
+}
+
+// MinVersion returns the min version of the platform
+func (g *VersionGate) MinVersion(platform Platform) (Version, bool) {
+	v, ok := (*g.mins.Load())[platform]
+	return v, ok
+}
+
+// Allow 版本不低于平台的最低版本时返回 true，没有配置最低版本的平台都允许
+func (g *VersionGate) Allow(platform Platform, v Version) bool {
+	minVersion, ok := g.MinVersion(platform)
+	return !ok || v.AtLeast(minVersion)
+}
+
+// VersionFrom 解析请求中的 app 版本
+func VersionFrom(ctx context.Context) (Version, bool) {
+	if info, ok := RequestInfoFrom(ctx); ok {
+		return info.version, info.versionOK
+	}
+
+	raw, ok := AppVersionFrom(ctx)
+	if !ok {
+		return Version{}, false
+	}
+
+	v, err := ParseVersion(raw)
+	return v, err == nil
+}
+
+type upgradeRequiredKey struct{}
+
+// WithUpgradeRequired 标记请求的版本低于最低版本
+func WithUpgradeRequired(ctx context.Context, minVersion Version) context.Context {
+	return context.WithValue(ctx, upgradeRequiredKey{}, minVersion)
+}
+
+// UpgradeRequiredFrom 请求的版本低于最低版本时返回最低版本
+func UpgradeRequiredFrom(ctx context.Context) (Version, bool) {
+	minVersion, ok := ctx.Value(upgradeRequiredKey{}).(Version)
+	return minVersion, ok
+}
@@ -0,0 +1,87 @@
+package icontext
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	for in, expected := range map[string]Version{
+		"3.12":           {Major: 3, Minor: 12},
+		"v3.12.0":        {Major: 3, Minor: 12},
+		"V3.12.0":        {Major: 3, Minor: 12},
+		"3.12.0.456":     {Major: 3, Minor: 12, Build: 456},
+		"3.12.0+456":     {Major: 3, Minor: 12, Build: 456},
+		"3.12.0(456)":    {Major: 3, Minor: 12, Build: 456},
+		"3.12.0-beta.1":  {Major: 3, Minor: 12, Pre: "beta.1"},
+		"3.12.1-rc1+789": {Major: 3, Minor: 12, Patch: 1, Pre: "rc1", Build: 789},
+	} {
+		v, err := ParseVersion(in)
+		assert.Nil(t, err, in)
+		assert.Equal(t, expected, v, in)
+	}
+
+	for _, in := range []string{"", "a.b", "1.2.3.4.5", "3.12.0(abc)", "3.12.0.456(789)", "3.12.0.456+789"} {
+		_, err := ParseVersion(in)
+		assert.NotNil(t, err, in)
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	ordered := []string{"3.9.9", "3.12.0-alpha", "3.12.0-beta.1", "3.12.0-beta.2", "3.12.0", "3.12.0.1", "3.12.1", "10.0"}
+	for i := 1; i < len(ordered); i++ {
+		assert.True(t, MustParseVersion(ordered[i-1]).LessThan(MustParseVersion(ordered[i])), ordered[i])
+	}
+	assert.Equal(t, 0, MustParseVersion("v3.12").Compare(MustParseVersion("3.12.0")))
+
+	r := MustParseVersionRange(">=3.12.0 <4.0.0 || =2.0.0")
+	assert.True(t, r.Match(MustParseVersion("3.12.0")))
+	assert.True(t, r.Match(MustParseVersion("2.0")))
+	assert.False(t, r.Match(MustParseVersion("3.11.9")))
+	assert.False(t, r.Match(MustParseVersion("4.0.0")))
+
+	// 忽略 build 号
+	for in, expected := range map[string]bool{
+		"=3.12.0":  true,
+		"<=3.12.0": true,
+		">=3.12.0": true,
+		">3.12.0":  false,
+		"<3.12.0":  false,
+		"!=3.12.0": false,
+	} {
+		assert.Equal(t, expected, MustParseVersionRange(in).Match(MustParseVersion("3.12.0(456)")), in)
+	}
+	assert.True(t, MustParseVersionRange("=3.12.0.456").Match(MustParseVersion("3.12.0+789")))
+
+	// 操作符后面可以有空格
+	r = MustParseVersionRange(">= 3.12.0, < 4.0.0")
+	assert.True(t, r.Match(MustParseVersion("3.12.0")))
+	assert.False(t, r.Match(MustParseVersion("4.0.0")))
+
+	for _, in := range []string{"", ">=", "3.12.0 ||", ">= >= 3.12.0"} {
+		_, err := ParseVersionRange(in)
+		assert.NotNil(t, err, in)
+	}
+}
+
+func TestVersionGate(t *testing.T) {
+	gate, err := NewVersionGate(map[string]string{string(IOS): "3.12.0"})
+	assert.Nil(t, err)
+
+	assert.False(t, gate.Allow(IOS, MustParseVersion("3.11.9")))
+	assert.True(t, gate.Allow(IOS, MustParseVersion("3.12.0")))
+	assert.True(t, gate.Allow(Android, MustParseVersion("1.0.0")))
+
+	assert.Nil(t, gate.Update(map[string]string{string(Android): "2.0.0"}))
+	assert.True(t, gate.Allow(IOS, MustParseVersion("3.11.9")))
+	assert.False(t, gate.Allow(Android, MustParseVersion("1.0.0")))
+
+	ctx := WithBasicData(context.Background(), "0,0,500,3.11.9(123)")
+	for _, c := range []context.Context{ctx, WithRequestInfo(ctx, ParseRequestInfo(ctx))} {
+		v, ok := VersionFrom(c)
+		assert.True(t, ok)
+		assert.Equal(t, Version{Major: 3, Minor: 11, Patch: 9, Build: 123}, v)
+	}
+}
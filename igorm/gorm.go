@@ -46,7 +46,7 @@ type (
 		LogLevel           int           `json:"log_level" yaml:"logLevel" env:"DP_MYSQL_DB_LOG"`
 		MaxOpen            int           `json:"max_open" yaml:"maxOpen" env:"DP_MYSQL_DB_MAX_OPEN" default:"100"`
 		MaxIdle            int           `json:"max_idle" yaml:"maxIdle" env:"DP_MYSQL_DB_MAX_IDLE" default:"10"`
-		MaxLifeTime        time.Duration `json:"max_life_time" yaml:"maxLifeTime" env:"DP_MYSQL_DB_MAX_LIFE_TIME" default:"8"`
+		MaxLifeTime        time.Duration `json:"max_life_time" yaml:"maxLifeTime" env:"DP_MYSQL_DB_MAX_LIFE_TIME" default:"8h"`
 		DisableQueryFields bool          `json:"disable_query_fields" yaml:"disableQueryFields" env:"DP_DISABLE_QUERY_FIELDS"`
 		CaPath             string        `json:"ca_path" yaml:"caPath"`
 		TLSConfig          *tls.Config   `json:"tls_config" yaml:"tlsConfig"`
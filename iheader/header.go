@@ -9,6 +9,8 @@ import (
 const (
 	ResponseContentJsonType       = "application/json"                // json 数据
 	ResponseContentTextType       = "text/plain"                      // 文本数据
+	ResponseContentProtobufType   = "application/x-protobuf"          // protobuf 数据
+	ResponseContentMsgpackType    = "application/msgpack"             // msgpack 数据
 	TraceIdHeaderKey              = "X-Trace-Id"                      // 链路追踪ID
 	TokenHeaderKey                = "X-Symbol"                        // 用户token
 	ForwardForHeaderKey           = "X-Forwarded-For"                 // 客户端ip
@@ -27,6 +29,13 @@ const (
 	SceneCodeKey                  = "SceneCode"                       // scene code
 	WSCKey                        = "Route_wsc_val"
 	XPWA                          = "X-Pwa"
+	UpgradeRequiredHeaderKey      = "X-Upgrade-Required"  // 低于最低版本时返回最低版本
+	RateLimitLimitHeaderKey       = "RateLimit-Limit"     // 窗口内允许的请求数
+	RateLimitRemainingHeaderKey   = "RateLimit-Remaining" // 窗口内剩余的请求数
+	RateLimitResetHeaderKey       = "RateLimit-Reset"     // 剩余的请求数恢复的秒数
+	RetryAfterHeaderKey           = "Retry-After"         // 被限流时重试的秒数
+	IdempotencyKeyHeaderKey       = "Idempotency-Key"     // 幂等key，相同的key只处理一次
+	IdempotentReplayedHeaderKey   = "Idempotent-Replayed" // 返回的是第一次请求的结果
 )
 
 func GetToken(h transport.Header) string {
@@ -1,8 +1,11 @@
 package kubernetes
 
 import (
+	"os"
+
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 const (
@@ -16,3 +19,17 @@ func NewClient() (*k8s.Clientset, error) {
 	}
 	return k8s.NewForConfig(config)
 }
+
+// NewClientWithKubeConfig 根据 kubeconfig 文件创建客户端，一般用于跨集群的服务发现
+// path 为空时读取环境变量 KUBE_CONFIG_PATH
+func NewClientWithKubeConfig(path string) (*k8s.Clientset, error) {
+	if path == "" {
+		path = os.Getenv(kubeConfigName)
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, err
+	}
+	return k8s.NewForConfig(config)
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"context"
+	stdErrors "errors"
+	"strings"
+
+	"github.com/airunny/wiki-go-tools/errors"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/airunny/wiki-go-tools/token"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type authOptions struct {
+	anonymous []string
+	roles     map[string][]int
+}
+
+type AuthOption func(*authOptions)
+
+// WithAnonymous 允许匿名访问的 operation，以 * 结尾时按前缀匹配；
+// 匿名的 operation 携带有效的 token 时依然会解析账号，token 无效时按匿名处理；
+// 同时匹配 WithRoles 的 operation 不允许匿名访问，例如 WithAnonymous("*") 与 WithRoles("/admin.*", ...) 同时使用时 /admin.* 依然需要认证
+//
+// kratos 中 operation 为 transport.Transporter.Operation()，例如 /api.user.v1.User/GetUser；
+// gin 中为路由的路径，例如 /v1/user/:id
+func WithAnonymous(operations ...string) AuthOption {
+	return func(o *authOptions) {
+		o.anonymous = append(o.anonymous, operations...)
+	}
+}
+
+// WithRoles operation 只允许这些角色（token.Account.Role）访问，operation 的格式同 WithAnonymous
+func WithRoles(operation string, roles ...int) AuthOption {
+	return func(o *authOptions) {
+		o.roles[operation] = append(o.roles[operation], roles...)
+	}
+}
+
+func newAuthOptions(opts ...AuthOption) *authOptions {
+	o := &authOptions{
+		roles: make(map[string][]int),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// authenticate 解析 token 并把账号保存到 context 中，没有认证的账号时清除客户端传入的用户ID
+func (o *authOptions) authenticate(ctx context.Context, j *token.JWT, operation, tokenString string) (context.Context, error) {
+	account, err := o.account(j, operation, tokenString)
+	if err != nil || account == nil {
+		return icontext.WithUserId(ctx, ""), err
+	}
+	return icontext.WithAccount(ctx, account), nil
+}
+
+// account 返回 token 中的账号，匿名访问时返回 nil
+func (o *authOptions) account(j *token.JWT, operation, tokenString string) (*token.Account, error) {
+	// 有角色限制的 operation 必须认证
+	anonymous := !o.restricted(operation) && matchOperations(o.anonymous, operation)
+	if tokenString == "" {
+		if anonymous {
+			return nil, nil
+		}
+		return nil, errors.ErrLogin
+	}
+
+	account, err := j.ParseToken(tokenString)
+	if err == nil && account == nil {
+		err = stdErrors.New("empty account")
+	}
+
+	if err != nil {
+		switch {
+		case anonymous:
+			return nil, nil
+		case stdErrors.Is(err, jwt.ErrTokenExpired):
+			return nil, errors.ErrAccessTokenExpired
+		}
+		return nil, errors.ErrLogin
+	}
+
+	for pattern, roles := range o.roles {
+		if matchOperation(pattern, operation) && !containsRole(roles, account.Role) {
+			return nil, errors.ErrForbidden
+		}
+	}
+	return account, nil
+}
+
+// restricted reports whether the operation matches any role rule
+func (o *authOptions) restricted(operation string) bool {
+	for pattern := range o.roles {
+		if matchOperation(pattern, operation) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOperations(patterns []string, operation string) bool {
+	for _, pattern := range patterns {
+		if matchOperation(pattern, operation) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOperation(pattern, operation string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(operation, prefix)
+	}
+	return pattern == operation
+}
+
+func containsRole(roles []int, role int) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Auth 从 iheader.TokenHeaderKey 中读取 token 并解析，账号见 icontext.AccountFrom，
+// token 过期时返回 errors.ErrAccessTokenExpired，没有 token 或 token 无效时返回 errors.ErrLogin，角色不符时返回 errors.ErrForbidden
+func Auth(j *token.JWT, opts ...AuthOption) middleware.Middleware {
+	o := newAuthOptions(opts...)
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			ctx, err := o.authenticate(ctx, j, tr.Operation(), iheader.GetToken(tr.RequestHeader()))
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
+// AuthForGin gin 的认证中间件，operation 为路由的路径（gin.Context.FullPath）
+func AuthForGin(j *token.JWT, opts ...AuthOption) gin.HandlerFunc {
+	o := newAuthOptions(opts...)
+	return func(c *gin.Context) {
+		header := headerCarrier(c.Request.Header)
+		ctx, err := o.authenticate(c.Request.Context(), j, c.FullPath(), iheader.GetToken(header))
+		if err != nil {
+			JSONError(c, err)
+			return
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
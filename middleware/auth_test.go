@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	stdHttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/airunny/wiki-go-tools/errors"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/airunny/wiki-go-tools/token"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthForGin(t *testing.T) {
+	j, err := token.NewJWT(&token.Config{Key: "test"})
+	assert.Nil(t, err)
+
+	user, err := j.GenerateToken(token.Account{ID: "user-1", Role: 1})
+	assert.Nil(t, err)
+	admin, err := j.GenerateToken(token.Account{ID: "admin-1", Role: 9})
+	assert.Nil(t, err)
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "user-1",
+		"exp":     time.Now().Add(-time.Minute).Unix(),
+	}).SignedString(j.GetKey())
+	assert.Nil(t, err)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(AuthForGin(j, WithAnonymous("/public/*"), WithRoles("/admin", 9)))
+	handler := func(c *gin.Context) {
+		userId, _ := icontext.UserIdFrom(c.Request.Context())
+		c.String(stdHttp.StatusOK, userId)
+	}
+	engine.GET("/public/news", handler)
+	engine.GET("/user", handler)
+	engine.GET("/admin", handler)
+
+	serve := func(path, tokenString string) (string, string) {
+		r := httptest.NewRequest(stdHttp.MethodGet, path, nil)
+		if tokenString != "" {
+			r.Header.Set(iheader.TokenHeaderKey, tokenString)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+
+		var res BizResponse
+		if json.Unmarshal(w.Body.Bytes(), &res) == nil {
+			return "", res.Reason
+		}
+		return w.Body.String(), ""
+	}
+
+	tests := []struct {
+		path   string
+		token  string
+		userId string
+		reason string
+	}{
+		{path: "/public/news", token: "", userId: "", reason: ""},
+		{path: "/public/news", token: user, userId: "user-1", reason: ""},
+		{path: "/public/news", token: expired, userId: "", reason: ""},
+		{path: "/user", token: "", reason: "UNAUTHORIZED"},
+		{path: "/user", token: "invalid", reason: "UNAUTHORIZED"},
+		{path: "/user", token: expired, reason: "ACCESS_TOKEN_EXPIRED"},
+		{path: "/user", token: user, userId: "user-1"},
+		{path: "/admin", token: user, reason: "FORBIDDEN"},
+		{path: "/admin", token: admin, userId: "admin-1"},
+	}
+	for _, test := range tests {
+		userId, reason := serve(test.path, test.token)
+		assert.Equal(t, test.userId, userId, test.path)
+		assert.Equal(t, test.reason, reason, test.path)
+	}
+}
+
+type testTransport struct {
+	operation string
+	header    headerCarrier
+	reply     headerCarrier
+}
+
+func (t *testTransport) Kind() transport.Kind            { return transport.KindHTTP }
+func (t *testTransport) Endpoint() string                { return "" }
+func (t *testTransport) Operation() string               { return t.operation }
+func (t *testTransport) RequestHeader() transport.Header { return t.header }
+func (t *testTransport) ReplyHeader() transport.Header   { return t.reply }
+
+func newTestTransport(operation string) *testTransport {
+	return &testTransport{
+		operation: operation,
+		header:    headerCarrier{},
+		reply:     headerCarrier{},
+	}
+}
+
+func TestAuth(t *testing.T) {
+	j, err := token.NewJWT(&token.Config{Key: "test"})
+	assert.Nil(t, err)
+
+	user, err := j.GenerateToken(token.Account{ID: "user-1", Role: 1})
+	assert.Nil(t, err)
+
+	handler := middleware.Chain(
+		TryParseHeader(WithoutGeoIP()),
+		Auth(j, WithAnonymous("/api.news.v1.News/*")),
+	)(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		userId, _ := icontext.UserIdFrom(ctx)
+		return userId, nil
+	})
+
+	tests := []struct {
+		operation string
+		token     string
+		userId    string
+		err       bool
+	}{
+		{operation: "/api.news.v1.News/List", token: "", userId: ""},
+		{operation: "/api.news.v1.News/List", token: "invalid", userId: ""},
+		{operation: "/api.news.v1.News/List", token: user, userId: "user-1"},
+		{operation: "/api.user.v1.User/Get", token: "", err: true},
+		{operation: "/api.user.v1.User/Get", token: user, userId: "user-1"},
+	}
+	for _, test := range tests {
+		tr := newTestTransport(test.operation)
+		// 客户端伪造的用户ID不生效
+		tr.header.Set(iheader.UserIdHeaderKey, "fake")
+		if test.token != "" {
+			tr.header.Set(iheader.TokenHeaderKey, test.token)
+		}
+
+		reply, err := handler(transport.NewServerContext(context.Background(), tr), nil)
+		if test.err {
+			assert.NotNil(t, err, test.operation)
+			continue
+		}
+		assert.Nil(t, err, test.operation)
+		assert.Equal(t, test.userId, reply, test.operation)
+	}
+
+	// 匿名规则与角色规则重叠时按角色规则认证
+	admin, err := j.GenerateToken(token.Account{ID: "admin-1", Role: 9})
+	assert.Nil(t, err)
+
+	handler = Auth(j, WithAnonymous("*"), WithRoles("/api.admin.v1.Admin/*", 9))(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		userId, _ := icontext.UserIdFrom(ctx)
+		return userId, nil
+	})
+
+	for _, test := range []struct {
+		operation string
+		token     string
+		userId    string
+		err       error
+	}{
+		{operation: "/api.news.v1.News/List", token: "", userId: ""},
+		{operation: "/api.admin.v1.Admin/Delete", token: "", err: errors.ErrLogin},
+		{operation: "/api.admin.v1.Admin/Delete", token: "forged", err: errors.ErrLogin},
+		{operation: "/api.admin.v1.Admin/Delete", token: user, err: errors.ErrForbidden},
+		{operation: "/api.admin.v1.Admin/Delete", token: admin, userId: "admin-1"},
+	} {
+		tr := newTestTransport(test.operation)
+		if test.token != "" {
+			tr.header.Set(iheader.TokenHeaderKey, test.token)
+		}
+
+		reply, err := handler(transport.NewServerContext(context.Background(), tr), nil)
+		assert.Equal(t, test.err, err, test.operation)
+		if test.err == nil {
+			assert.Equal(t, test.userId, reply, test.operation)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	stdHttp "net/http"
+	"strings"
+	"time"
+
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/gorilla/handlers"
+)
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	// AllowOrigins 允许的来源，支持 * 以及通配子域名，例如 https://*.wikifx.com
+	AllowOrigins []string
+	AllowHeaders []string
+	AllowMethods []string
+	// ExposeHeaders 浏览器可以读取的响应头
+	ExposeHeaders []string
+	// AllowCredentials 允许携带 cookie，AllowOrigins 包含 * 时响应头返回 *，浏览器不会携带 cookie，需要配置具体的来源
+	AllowCredentials bool
+	// MaxAge 预检请求的缓存时间，0 表示不缓存
+	MaxAge time.Duration
+}
+
+// DefaultCORSConfig 允许所有来源，请求头为 iheader 中客户端使用的请求头
+func DefaultCORSConfig() *CORSConfig {
+	return &CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowHeaders: []string{
+			"Authorization",
+			"Content-Type",
+			iheader.TokenHeaderKey,
+			iheader.UserIdHeaderKey,
+			iheader.RequestIdKey,
+			iheader.CountryCodeHeaderKey,
+			iheader.CountryCodeHeaderKeyOld,
+			iheader.LanguageCodeHeaderKey,
+			iheader.LanguageCodeHeaderKeyOld,
+			iheader.PreferredLanguageHeaderKey,
+			iheader.PreferredLanguageHeaderKeyOld,
+			iheader.BasicDataHeaderKey,
+			iheader.SceneCodeKey,
+			iheader.WSCKey,
+			iheader.XPWA,
+			iheader.IdempotencyKeyHeaderKey,
+		},
+		AllowMethods: []string{"GET", "POST", "PUT", "HEAD", "OPTIONS", "DELETE"},
+		ExposeHeaders: []string{
+			iheader.RequestIdKey,
+			iheader.TraceIdHeaderKey,
+			iheader.UpgradeRequiredHeaderKey,
+			iheader.RateLimitLimitHeaderKey,
+			iheader.RateLimitRemainingHeaderKey,
+			iheader.RateLimitResetHeaderKey,
+			iheader.RetryAfterHeaderKey,
+			iheader.IdempotentReplayedHeaderKey,
+		},
+	}
+}
+
+// AllowOrigin reports whether the origin matches the AllowOrigins
+func (c *CORSConfig) AllowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range c.AllowOrigins {
+		if matchOrigin(strings.ToLower(pattern), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowAll reports whether the AllowOrigins contains *
+func (c *CORSConfig) allowAll() bool {
+	for _, pattern := range c.AllowOrigins {
+		if pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSConfig) options() []handlers.CORSOption {
+	// 使用 validator 时响应头中返回请求的 Origin，允许携带 cookie 时浏览器不接受 *；
+	// 允许所有来源时返回 *，避免任意来源都可以携带 cookie 访问
+	origins := handlers.AllowedOriginValidator(c.AllowOrigin)
+	if c.allowAll() {
+		origins = handlers.AllowedOrigins([]string{"*"})
+	}
+
+	opts := []handlers.CORSOption{
+		origins,
+		handlers.AllowedHeaders(c.AllowHeaders),
+		handlers.AllowedMethods(c.AllowMethods),
+		handlers.ExposedHeaders(c.ExposeHeaders),
+		handlers.MaxAge(int(c.MaxAge / time.Second)),
+		handlers.OptionStatusCode(204),
+	}
+
+	if c.AllowCredentials {
+		opts = append(opts, handlers.AllowCredentials())
+	}
+	return opts
+}
+
+func (c *CORSConfig) handler(next stdHttp.Handler) stdHttp.Handler {
+	h := handlers.CORS(c.options()...)(next)
+	return stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, r *stdHttp.Request) {
+		// 返回的 Allow-Origin 随 Origin 变化
+		w.Header().Add("Vary", "Origin")
+		h.ServeHTTP(w, r)
+	})
+}
+
+// matchOrigin matches the origin with the pattern, the * in the pattern matches one or more subdomains
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+
+	i := strings.IndexByte(pattern, '*')
+	if i < 0 {
+		return false
+	}
+
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	return !strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:")
+}
+
+func CORS() http.FilterFunc {
+	return CORSWithConfig(DefaultCORSConfig())
+}
+
+func CORSWithConfig(c *CORSConfig) http.FilterFunc {
+	return c.handler
+}
+
+type ginContextKey struct{}
+
+// CORSForGin gin 的跨域中间件，预检请求以及不允许的来源的预检请求不会进入后续的 handler
+func CORSForGin(c *CORSConfig) gin.HandlerFunc {
+	h := c.handler(stdHttp.HandlerFunc(func(_ stdHttp.ResponseWriter, r *stdHttp.Request) {
+		c := r.Context().Value(ginContextKey{}).(*gin.Context)
+		c.Next()
+	}))
+
+	return func(c *gin.Context) {
+		r := c.Request.WithContext(context.WithValue(c.Request.Context(), ginContextKey{}, c))
+		h.ServeHTTP(c.Writer, r)
+		c.Abort()
+	}
+}
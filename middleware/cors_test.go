@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	stdHttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	assert.True(t, matchOrigin("*", "https://a.com"))
+	assert.True(t, matchOrigin("https://*.wikifx.com", "https://m.wikifx.com"))
+	assert.True(t, matchOrigin("https://*.wikifx.com", "https://a.b.wikifx.com"))
+	assert.False(t, matchOrigin("https://*.wikifx.com", "https://wikifx.com"))
+	assert.False(t, matchOrigin("https://*.wikifx.com", "https://evil-wikifx.com"))
+	assert.False(t, matchOrigin("https://*.wikifx.com", "http://m.wikifx.com"))
+}
+
+func TestCORS(t *testing.T) {
+	cfg := DefaultCORSConfig()
+	cfg.AllowOrigins = []string{"https://*.wikifx.com"}
+	cfg.AllowCredentials = true
+	cfg.MaxAge = time.Minute
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(CORSForGin(cfg))
+	engine.GET("/", func(c *gin.Context) {
+		c.String(stdHttp.StatusOK, "ok")
+	})
+
+	handlers := map[string]stdHttp.Handler{
+		"kratos": CORSWithConfig(cfg)(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, _ *stdHttp.Request) {
+			_, _ = w.Write([]byte("ok"))
+		})),
+		"gin": engine,
+	}
+
+	for name, h := range handlers {
+		// 预检请求
+		r := httptest.NewRequest(stdHttp.MethodOptions, "/", nil)
+		r.Header.Set("Origin", "https://m.wikifx.com")
+		r.Header.Set("Access-Control-Request-Method", stdHttp.MethodGet)
+		r.Header.Set("Access-Control-Request-Headers", iheader.CountryCodeHeaderKey+","+iheader.LanguageCodeHeaderKey)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, stdHttp.StatusNoContent, w.Code, name)
+		assert.Equal(t, "https://m.wikifx.com", w.Header().Get("Access-Control-Allow-Origin"), name)
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"), name)
+		assert.Equal(t, "60", w.Header().Get("Access-Control-Max-Age"), name)
+		assert.Empty(t, w.Body.String(), name)
+
+		// 普通请求
+		r = httptest.NewRequest(stdHttp.MethodGet, "/", nil)
+		r.Header.Set("Origin", "https://m.wikifx.com")
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, "ok", w.Body.String(), name)
+		assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), iheader.TraceIdHeaderKey, name)
+
+		// 不允许的来源
+		r = httptest.NewRequest(stdHttp.MethodGet, "/", nil)
+		r.Header.Set("Origin", "https://evil.com")
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, "ok", w.Body.String(), name)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), name)
+	}
+}
+
+func TestCORSAllowAllWithCredentials(t *testing.T) {
+	cfg := DefaultCORSConfig()
+	cfg.AllowCredentials = true
+
+	h := CORSWithConfig(cfg)(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, _ *stdHttp.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	// 允许所有来源时不返回请求的 Origin，浏览器不会携带 cookie
+	r := httptest.NewRequest(stdHttp.MethodGet, "/", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
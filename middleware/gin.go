@@ -42,32 +42,15 @@ func TraceIdAndRequestIdWithHeaderForGin() gin.HandlerFunc {
 	}
 }
 
-func TryParseHeaderForGin() gin.HandlerFunc {
+func TryParseHeaderForGin(opts ...Option) gin.HandlerFunc {
+	o := newOptions(opts...)
 	return func(c *gin.Context) {
 		var (
 			ctx    = c.Request.Context()
 			header = headerCarrier(c.Request.Header)
 		)
 
-		// 客户端ip
-		ctx = icontext.WithClientIP(ctx, iheader.GetClientIp(header))
-		// 用户ID
-		ctx = icontext.WithUserId(ctx, iheader.GetUserId(header))
-		// basic data
-		ctx = icontext.WithBasicData(ctx, iheader.GetBasicData(header))
-		// 城市码
-		countryCode := iheader.GetCountryCode(header)
-		ctx = icontext.WithCountryCode(ctx, countryCode)
-		// 语言code
-		ctx = icontext.WithLanguageCode(ctx, iheader.GetLanguageCode(header))
-		// 偏好语言
-		ctx = icontext.WithPreferredLanguageCode(ctx, iheader.GetPreferredLanguageCode(header))
-		// wiki data center Request-Id
-		ctx = icontext.WithWikiDataCenterRequestId(ctx, iheader.GetWikiDataCenterRequestId(header))
-		// scene code
-		ctx = icontext.WithSceneCode(ctx, iheader.GetSceneCode(header))
-		// x-pwa
-		ctx = icontext.WithXPWA(ctx, iheader.GetXPwa(header))
+		ctx = o.parseHeader(ctx, header)
 		// wsc
 		wscValue := iheader.GetRouteWSC(header)
 		ctx = icontext.WithWSC(ctx, wscValue)
@@ -116,7 +99,7 @@ func JSONError(c *gin.Context, err error) {
 	e := errors.FromError(err)
 	c.JSON(http.StatusOK, BizResponse{
 		Code:    e.Code,
-		Message: e.Message,
+		Message: localizeMessage(c.Request.Context(), e.Reason, e.Message, e.Metadata),
 		Reason:  e.Reason,
 		Time:    time.Now().Unix(),
 	})
@@ -8,31 +8,13 @@ import (
 	"github.com/airunny/wiki-go-tools/icontext"
 	"github.com/airunny/wiki-go-tools/iheader"
 	"github.com/airunny/wiki-go-tools/reqid"
-	"github.com/go-kratos/kratos/v2/encoding"
-	"github.com/go-kratos/kratos/v2/encoding/json"
 	"github.com/go-kratos/kratos/v2/errors"
 	"github.com/go-kratos/kratos/v2/middleware"
 	"github.com/go-kratos/kratos/v2/transport"
 	"github.com/go-kratos/kratos/v2/transport/http"
-	"github.com/gorilla/handlers"
 	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	allowOrigins = []string{"*"}
-	allowHeaders = []string{"X-Symbol", "Authorization", "Content-Type", "X-User-Id"}
-	allowMethods = []string{"GET", "POST", "PUT", "HEAD", "OPTIONS", "DELETE"}
-)
-
-func CORS() http.FilterFunc {
-	return handlers.CORS(
-		handlers.AllowedOrigins(allowOrigins),
-		handlers.AllowedHeaders(allowHeaders),
-		handlers.AllowedMethods(allowMethods),
-		handlers.OptionStatusCode(204),
-	)
-}
-
 func TraceIdAndRequestIdWithHeader(handler middleware.Handler) middleware.Handler {
 	return func(ctx context.Context, req interface{}) (interface{}, error) {
 		tr, ok := transport.FromServerContext(ctx)
@@ -66,11 +48,7 @@ func TraceIdAndRequestIdWithHeader(handler middleware.Handler) middleware.Handle
 }
 
 func TryParseHeader(opts ...Option) middleware.Middleware {
-	o := Options{}
-	for _, opt := range opts {
-		opt(o)
-	}
-
+	o := newOptions(opts...)
 	return func(handler middleware.Handler) middleware.Handler {
 		return func(ctx context.Context, req interface{}) (interface{}, error) {
 			tr, ok := transport.FromServerContext(ctx)
@@ -79,25 +57,8 @@ func TryParseHeader(opts ...Option) middleware.Middleware {
 			}
 
 			header := tr.RequestHeader()
-			// 客户端ip
-			ctx = icontext.WithClientIP(ctx, iheader.GetClientIp(header))
-			// 用户ID
-			ctx = icontext.WithUserId(ctx, iheader.GetUserId(header))
-			// basic data
-			ctx = icontext.WithBasicData(ctx, iheader.GetBasicData(header))
-			// 城市码
-			countryCode := iheader.GetCountryCode(header)
-			ctx = icontext.WithCountryCode(ctx, countryCode)
-			// 语言code
-			ctx = icontext.WithLanguageCode(ctx, iheader.GetLanguageCode(header))
-			// 偏好语言
-			ctx = icontext.WithPreferredLanguageCode(ctx, iheader.GetPreferredLanguageCode(header))
-			// wiki data center Request-Id
-			ctx = icontext.WithWikiDataCenterRequestId(ctx, iheader.GetWikiDataCenterRequestId(header))
-			// scene code
-			ctx = icontext.WithSceneCode(ctx, iheader.GetSceneCode(header))
-			// x-pwa
-			ctx = icontext.WithXPWA(ctx, iheader.GetXPwa(header))
+			ctx = o.parseHeader(ctx, header)
+			saveRequestContext(ctx)
 			// wsc
 			wscValue := iheader.GetRouteWSC(header)
 			ctx = icontext.WithWSC(ctx, wscValue)
@@ -142,19 +103,21 @@ func ResponseEncoder(w http.ResponseWriter, r *stdHttp.Request, v interface{}) e
 	return nil
 }
 
+// ErrorEncoder 错误信息按请求的语言返回，见 RegisterErrorMessage、RequestContext
 func ErrorEncoder(w http.ResponseWriter, r *stdHttp.Request, err error) {
-	WriteResponse(w, r, ResponseWithError(errors.FromError(err)))
+	WriteResponse(w, r, ResponseWithErrorContext(RequestContextFrom(r), errors.FromError(err)))
 }
 
-func WriteResponse(w http.ResponseWriter, _ *stdHttp.Request, body interface{}) {
-	codec := encoding.GetCodec(json.Name)
-	data, err := codec.Marshal(body)
+// WriteResponse 根据 Accept 返回 json、protobuf 或 msgpack
+func WriteResponse(w http.ResponseWriter, r *stdHttp.Request, body interface{}) {
+	contentType, data, err := encodeResponse(r, body)
 	if err != nil {
 		w.WriteHeader(stdHttp.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", iheader.ResponseContentJsonType)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
 	_, err = w.Write(data)
 	if err != nil {
 		w.WriteHeader(stdHttp.StatusInternalServerError)
@@ -0,0 +1,393 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stdErrors "errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/airunny/wiki-go-tools/errors"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/airunny/wiki-go-tools/locker"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/encoding"
+	"github.com/go-kratos/kratos/v2/encoding/json"
+	kratosErrors "github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
+	redis "github.com/go-redis/redis/v8"
+)
+
+type idempotencyOptions struct {
+	prefix      string
+	ttl         time.Duration
+	lockExpires time.Duration
+	operations  []string
+}
+
+type IdempotencyOption func(*idempotencyOptions)
+
+// WithIdempotencyPrefix redis key 的前缀，默认 idempotency:
+func WithIdempotencyPrefix(prefix string) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithIdempotencyTTL 第一次请求的结果保存的时间，默认 24 小时
+func WithIdempotencyTTL(ttl time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithIdempotencyLockExpires 处理请求时锁的过期时间，需要大于请求的处理时间，默认 1 分钟
+func WithIdempotencyLockExpires(expires time.Duration) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.lockExpires = expires
+	}
+}
+
+// WithIdempotencyOperations 只对这些 operation 生效，以 * 结尾时按前缀匹配，默认所有携带 Idempotency-Key 的请求
+func WithIdempotencyOperations(operations ...string) IdempotencyOption {
+	return func(o *idempotencyOptions) {
+		o.operations = append(o.operations, operations...)
+	}
+}
+
+// idempotentRecord 第一次请求的响应，Fingerprint 为第一次请求的 method、路径以及请求体的摘要
+type idempotentRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// idempotentReply replays the record by the ResponseEncoder
+type idempotentReply struct {
+	record *idempotentRecord
+}
+
+func (r *idempotentReply) Body() []byte {
+	return r.record.Body
+}
+
+func (r *idempotentReply) ContentType() string {
+	return r.record.ContentType
+}
+
+// idempotentStore 保存第一次请求的响应，key 不存在时 Get 返回 nil
+type idempotentStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, data []byte, ttl time.Duration) error
+}
+
+type redisIdempotentStore struct {
+	redisCli *redis.Client
+}
+
+func (s *redisIdempotentStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.redisCli.Get(ctx, key).Bytes()
+	if stdErrors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *redisIdempotentStore) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return s.redisCli.Set(ctx, key, data, ttl).Err()
+}
+
+type idempotency struct {
+	store  idempotentStore
+	locker locker.Locker
+	o      *idempotencyOptions
+}
+
+func newIdempotency(store idempotentStore, l locker.Locker, opts ...IdempotencyOption) *idempotency {
+	o := &idempotencyOptions{
+		prefix:      "idempotency:",
+		ttl:         24 * time.Hour,
+		lockExpires: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &idempotency{
+		store:  store,
+		locker: l,
+		o:      o,
+	}
+}
+
+// key 幂等key按 operation 以及认证的用户隔离，不同用户使用相同的key互不影响，匿名请求不处理
+func (i *idempotency) key(ctx context.Context, operation, key string) (string, bool) {
+	account, ok := icontext.AccountFrom(ctx)
+	if !ok || account.ID == "" {
+		return "", false
+	}
+	return i.o.prefix + operation + ":" + account.ID + ":" + key, true
+}
+
+// fingerprint 请求的摘要，相同的幂等key用于不同的请求时返回 errors.ErrIdempotentMismatch
+func fingerprint(method, uri string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + uri + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (i *idempotency) enabled(operation, key string) bool {
+	return key != "" && (len(i.o.operations) == 0 || matchOperations(i.o.operations, operation))
+}
+
+func (i *idempotency) load(ctx context.Context, key, fingerprint string) (*idempotentRecord, error) {
+	data, err := i.store.Get(ctx, key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var record idempotentRecord
+	if err = encoding.GetCodec(json.Name).Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	if record.Fingerprint != fingerprint {
+		return nil, errors.ErrIdempotentMismatch
+	}
+	return &record, nil
+}
+
+// do 第一次请求执行 fn 并保存结果，重复的请求返回第一次请求的结果，第一次请求还在处理中时返回 errors.ErrRequestInProgress，
+// 与第一次请求的 fingerprint 不同时返回 errors.ErrIdempotentMismatch
+func (i *idempotency) do(ctx context.Context, key, fingerprint string, fn func() *idempotentRecord) (*idempotentRecord, bool, error) {
+	record, err := i.load(ctx, key, fingerprint)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if record != nil {
+		return record, true, nil
+	}
+
+	release, err := i.locker.TryLock(ctx, key+":lock", i.o.lockExpires)
+	if err != nil {
+		if !stdErrors.Is(err, locker.ErrAlreadyLocked) {
+			return nil, false, err
+		}
+
+		// 加锁失败时第一次请求可能刚处理完
+		if record, err = i.load(ctx, key, fingerprint); err != nil || record != nil {
+			return record, record != nil, err
+		}
+		return nil, false, errors.ErrRequestInProgress
+	}
+	defer func() { _ = release() }()
+
+	// 获取锁之前第一次请求可能刚处理完
+	if record, err = i.load(ctx, key, fingerprint); err != nil || record != nil {
+		return record, record != nil, err
+	}
+
+	record = fn()
+	if record == nil {
+		return nil, false, nil
+	}
+	record.Fingerprint = fingerprint
+
+	// 请求已经处理完，保存结果失败时只记录日志
+	data, err := encoding.GetCodec(json.Name).Marshal(record)
+	if err == nil {
+		err = i.store.Set(context.Background(), key, data, i.o.ttl)
+	}
+
+	if err != nil {
+		log.Context(ctx).Errorf("save idempotent response %s error: %v", key, err)
+	}
+	return record, false, nil
+}
+
+// storable 服务端错误不保存，客户端可以重试
+func storable(status int, code int32) bool {
+	return status < http.StatusInternalServerError && code < http.StatusInternalServerError
+}
+
+// recordOf 与 ResponseEncoder、ErrorEncoder 输出的内容一致，按第一次请求的 Accept 编码，重复的请求需要使用相同的 Accept
+func recordOf(ctx context.Context, r *http.Request, reply interface{}, err error) *idempotentRecord {
+	var body interface{}
+	if err != nil {
+		res := ResponseWithErrorContext(ctx, kratosErrors.FromError(err))
+		if !storable(http.StatusOK, res.Code) {
+			return nil
+		}
+		body = res
+	} else {
+		switch v := reply.(type) {
+		case nil:
+			return &idempotentRecord{Status: http.StatusOK}
+		case khttp.Redirector:
+			return nil
+		case TextPlainReply:
+			return &idempotentRecord{
+				Status:      http.StatusOK,
+				ContentType: iheader.ResponseContentTextType,
+				Body:        []byte(v.StringReply()),
+			}
+		case CustomReply:
+			return &idempotentRecord{
+				Status:      http.StatusOK,
+				ContentType: v.ContentType(),
+				Body:        v.Body(),
+			}
+		}
+		body = ResponseWithData(reply)
+	}
+
+	contentType, data, err := encodeResponse(r, body)
+	if err != nil {
+		return nil
+	}
+
+	return &idempotentRecord{
+		Status:      http.StatusOK,
+		ContentType: contentType,
+		Body:        data,
+	}
+}
+
+// Idempotent 携带 Idempotency-Key 的请求只处理一次，在 ttl 内重复的请求直接返回第一次请求的响应并设置 Idempotent-Replayed 头，
+// 第一次请求还在处理中时返回 errors.ErrRequestInProgress，相同的key用于不同的请求时返回 errors.ErrIdempotentMismatch；
+// 只对 http 请求生效，需要在 Auth 之后使用，按认证的用户隔离，匿名请求不处理
+//
+//	l, err := locker.NewLockerWithRedis(redisCli)
+//	middleware.Idempotent(redisCli, l, middleware.WithIdempotencyOperations("/api.order.v1.Order/*"))
+func Idempotent(cli *redis.Client, l locker.Locker, opts ...IdempotencyOption) middleware.Middleware {
+	i := newIdempotency(&redisIdempotentStore{redisCli: cli}, l, opts...)
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok || tr.Kind() != transport.KindHTTP {
+				return handler(ctx, req)
+			}
+
+			key := tr.RequestHeader().Get(iheader.IdempotencyKeyHeaderKey)
+			if !i.enabled(tr.Operation(), key) {
+				return handler(ctx, req)
+			}
+
+			r, ok := khttp.RequestFromServerContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			key, ok = i.key(ctx, tr.Operation(), key)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			// 请求体已经被解码，使用解码后的请求计算摘要
+			body, err := encoding.GetCodec(json.Name).Marshal(req)
+			if err != nil {
+				return nil, err
+			}
+
+			var (
+				reply    interface{}
+				replyErr error
+			)
+			record, replayed, err := i.do(ctx, key, fingerprint(r.Method, r.URL.RequestURI(), body), func() *idempotentRecord {
+				reply, replyErr = handler(ctx, req)
+				return recordOf(ctx, r, reply, replyErr)
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			if !replayed {
+				return reply, replyErr
+			}
+
+			tr.ReplyHeader().Set(iheader.IdempotentReplayedHeaderKey, "true")
+			return &idempotentReply{record: record}, nil
+		}
+	}
+}
+
+// recordWriter records the response written by the gin handlers
+type recordWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotentForGin gin 的幂等中间件，operation 为路由的路径（gin.Context.FullPath）
+func IdempotentForGin(cli *redis.Client, l locker.Locker, opts ...IdempotencyOption) gin.HandlerFunc {
+	return newIdempotency(&redisIdempotentStore{redisCli: cli}, l, opts...).handleGin
+}
+
+func (i *idempotency) handleGin(c *gin.Context) {
+	key := c.GetHeader(iheader.IdempotencyKeyHeaderKey)
+	if !i.enabled(c.FullPath(), key) {
+		c.Next()
+		return
+	}
+
+	ctx := c.Request.Context()
+	key, ok := i.key(ctx, c.FullPath(), key)
+	if !ok {
+		c.Next()
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		JSONError(c, errors.ErrBadRequest)
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	record, replayed, err := i.do(ctx, key, fingerprint(c.Request.Method, c.Request.URL.RequestURI(), body), func() *idempotentRecord {
+		w := &recordWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		var res BizResponse
+		_ = encoding.GetCodec(json.Name).Unmarshal(w.body.Bytes(), &res)
+		if !storable(w.Status(), res.Code) {
+			return nil
+		}
+
+		return &idempotentRecord{
+			Status:      w.Status(),
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+		}
+	})
+
+	switch {
+	case err != nil:
+		JSONError(c, err)
+	case replayed:
+		c.Header(iheader.IdempotentReplayedHeaderKey, "true")
+		c.Data(record.Status, record.ContentType, record.Body)
+		c.Abort()
+	}
+}
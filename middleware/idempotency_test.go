@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"context"
+	stdHttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/airunny/wiki-go-tools/encoding/msgpack"
+	"github.com/airunny/wiki-go-tools/errors"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/airunny/wiki-go-tools/locker"
+	"github.com/airunny/wiki-go-tools/token"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/encoding"
+	"github.com/go-kratos/kratos/v2/encoding/json"
+	kratosErrors "github.com/go-kratos/kratos/v2/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testReply struct {
+	OrderId string `json:"order_id"`
+}
+
+func TestRecordOf(t *testing.T) {
+	record := recordOf(context.Background(), nil, &testReply{OrderId: "1"}, nil)
+	assert.Equal(t, iheader.ResponseContentJsonType, record.ContentType)
+
+	var res BizResponse
+	assert.Nil(t, encoding.GetCodec(json.Name).Unmarshal(record.Body, &res))
+	assert.Equal(t, int32(200), res.Code)
+	assert.Equal(t, map[string]interface{}{"order_id": "1"}, res.Data)
+
+	record = recordOf(context.Background(), nil, nil, errors.ErrOutOfStock)
+	assert.Nil(t, encoding.GetCodec(json.Name).Unmarshal(record.Body, &res))
+	assert.Equal(t, "OUT_OF_STOCK", res.Reason)
+
+	// 服务端错误不保存，客户端可以重试
+	assert.Nil(t, recordOf(context.Background(), nil, nil, errors.ErrInternalServer))
+
+	// 按 Accept 编码，重复的请求返回相同格式的响应
+	r := httptest.NewRequest(stdHttp.MethodPost, "/", nil)
+	r.Header.Set("Accept", iheader.ResponseContentMsgpackType)
+	record = recordOf(context.Background(), r, &testReply{OrderId: "1"}, nil)
+	assert.Equal(t, iheader.ResponseContentMsgpackType, record.ContentType)
+
+	res = BizResponse{}
+	assert.Nil(t, encoding.GetCodec(msgpack.Name).Unmarshal(record.Body, &res))
+	assert.Equal(t, int32(200), res.Code)
+}
+
+type testIdempotentStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newTestIdempotentStore() *testIdempotentStore {
+	return &testIdempotentStore{data: make(map[string][]byte)}
+}
+
+func (s *testIdempotentStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key], nil
+}
+
+func (s *testIdempotentStore) Set(_ context.Context, key string, data []byte, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = data
+	return nil
+}
+
+// testLocker 单机的 locker.Locker，onLock 在获取锁之后调用
+type testLocker struct {
+	mu     sync.Mutex
+	locked map[string]bool
+	onLock func(key string)
+}
+
+func newTestLocker() *testLocker {
+	return &testLocker{locked: make(map[string]bool)}
+}
+
+func (l *testLocker) Lock(ctx context.Context, key string, expires, _ time.Duration) (locker.Release, error) {
+	return l.TryLock(ctx, key, expires)
+}
+
+func (l *testLocker) TryLock(_ context.Context, key string, _ time.Duration) (locker.Release, error) {
+	l.mu.Lock()
+	if l.locked[key] {
+		l.mu.Unlock()
+		return nil, locker.ErrAlreadyLocked
+	}
+	l.locked[key] = true
+	l.mu.Unlock()
+
+	if l.onLock != nil {
+		l.onLock(key)
+	}
+
+	return func() error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.locked, key)
+		return nil
+	}, nil
+}
+
+func TestIdempotencyDo(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		store   = newTestIdempotentStore()
+		l       = newTestLocker()
+		i       = newIdempotency(store, l)
+		calls   int
+		handler = func() *idempotentRecord {
+			calls++
+			return &idempotentRecord{Status: stdHttp.StatusOK, Body: []byte("ok")}
+		}
+	)
+
+	// 第一次请求执行，重复的请求返回第一次的结果
+	record, replayed, err := i.do(ctx, "k1", "f1", handler)
+	assert.Nil(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, "ok", string(record.Body))
+
+	record, replayed, err = i.do(ctx, "k1", "f1", handler)
+	assert.Nil(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, "ok", string(record.Body))
+	assert.Equal(t, 1, calls)
+
+	// 相同的key用于不同的请求
+	_, _, err = i.do(ctx, "k1", "f2", handler)
+	assert.Equal(t, "IDEMPOTENT_MISMATCH", kratosErrors.FromError(err).Reason)
+
+	// 第一次请求还在处理中
+	release, err := l.TryLock(ctx, "k2:lock", time.Minute)
+	assert.Nil(t, err)
+	_, _, err = i.do(ctx, "k2", "f1", handler)
+	assert.Equal(t, int32(409), kratosErrors.FromError(err).Code)
+	assert.Equal(t, "REQUEST_IN_PROGRESS", kratosErrors.FromError(err).Reason)
+
+	// 加锁失败时第一次请求刚处理完
+	assert.Nil(t, store.Set(ctx, "k2", []byte(`{"fingerprint":"f1","status":200,"body":"b2s="}`), time.Minute))
+	record, replayed, err = i.do(ctx, "k2", "f1", handler)
+	assert.Nil(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, "ok", string(record.Body))
+	assert.Nil(t, release())
+
+	// 获取锁之前第一次请求刚处理完
+	l.onLock = func(string) {
+		_ = store.Set(ctx, "k3", []byte(`{"fingerprint":"f1","status":200,"body":"b2s="}`), time.Minute)
+	}
+	record, replayed, err = i.do(ctx, "k3", "f1", handler)
+	assert.Nil(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, "ok", string(record.Body))
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotentForGin(t *testing.T) {
+	var (
+		i     = newIdempotency(newTestIdempotentStore(), newTestLocker())
+		calls int
+	)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		if userId := c.GetHeader(iheader.UserIdHeaderKey); userId != "" {
+			c.Request = c.Request.WithContext(icontext.WithAccount(c.Request.Context(), &token.Account{ID: userId}))
+		}
+	}, i.handleGin)
+	engine.POST("/orders", func(c *gin.Context) {
+		calls++
+		c.JSON(stdHttp.StatusOK, ResponseWithData(&testReply{OrderId: strconv.Itoa(calls)}))
+	})
+
+	serve := func(userId, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(stdHttp.MethodPost, "/orders", strings.NewReader(body))
+		r.Header.Set(iheader.IdempotencyKeyHeaderKey, "k1")
+		r.Header.Set(iheader.UserIdHeaderKey, userId)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+
+	first := serve("user-1", `{"goods":1}`)
+	assert.Empty(t, first.Header().Get(iheader.IdempotentReplayedHeaderKey))
+
+	second := serve("user-1", `{"goods":1}`)
+	assert.Equal(t, "true", second.Header().Get(iheader.IdempotentReplayedHeaderKey))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, first.Header().Get("Content-Type"), second.Header().Get("Content-Type"))
+	assert.Equal(t, 1, calls)
+
+	// 相同的key用于不同的请求体
+	mismatch := serve("user-1", `{"goods":2}`)
+	assert.Contains(t, mismatch.Body.String(), "IDEMPOTENT_MISMATCH")
+	assert.Equal(t, 1, calls)
+
+	// 不同的用户互不影响，匿名请求不处理
+	assert.Empty(t, serve("user-2", `{"goods":1}`).Header().Get(iheader.IdempotentReplayedHeaderKey))
+	assert.Empty(t, serve("", `{"goods":1}`).Header().Get(iheader.IdempotentReplayedHeaderKey))
+	assert.Empty(t, serve("", `{"goods":1}`).Header().Get(iheader.IdempotentReplayedHeaderKey))
+	assert.Equal(t, 4, calls)
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	stdHttp "net/http"
+	"sort"
+	"sync"
+
+	"github.com/airunny/wiki-go-tools/i18n"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// ErrorMessage 错误的多语言配置，Key 为 i18n 的 key，Args 为模板中 {0}、{1}... 对应的 metadata 的 key
+type ErrorMessage struct {
+	Key  string
+	Args []string
+}
+
+var (
+	errorMessagesMux sync.RWMutex
+	errorMessages    = make(map[string]*ErrorMessage)
+)
+
+// RegisterErrorMessage 设置 reason 对应的多语言配置，没有配置的 reason 使用 reason 作为 i18n 的 key，
+// 模板参数为按 key 排序的 metadata 的值
+//
+//	middleware.RegisterErrorMessage("OUT_OF_STOCK", &middleware.ErrorMessage{Key: "200101", Args: []string{"goods"}})
+func RegisterErrorMessage(reason string, message *ErrorMessage) {
+	errorMessagesMux.Lock()
+	defer errorMessagesMux.Unlock()
+	errorMessages[reason] = message
+}
+
+func errorMessageOf(reason string, metadata map[string]string) (string, []string) {
+	errorMessagesMux.RLock()
+	message, ok := errorMessages[reason]
+	errorMessagesMux.RUnlock()
+
+	if ok {
+		args := make([]string, 0, len(message.Args))
+		for _, arg := range message.Args {
+			args = append(args, metadata[arg])
+		}
+		return message.Key, args
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, metadata[key])
+	}
+	return reason, args
+}
+
+// localizeMessage 按请求的语言返回错误信息，没有翻译时保留原始的信息
+func localizeMessage(ctx context.Context, reason, message string, metadata map[string]string) string {
+	languageCode, ok := icontext.LanguageCodeFrom(ctx)
+	if !ok || reason == "" {
+		return message
+	}
+
+	key, args := errorMessageOf(reason, metadata)
+	if out := i18n.GetWithTemplateDataDefaultEnglish(key, languageCode, args); out != "" {
+		return out
+	}
+	return message
+}
+
+// hasErrorDetail 参数错误（CODEC、VALIDATOR）的 Message 为具体的错误信息（字段路径等），翻译后需要保留
+func hasErrorDetail(res *BizResponse) bool {
+	switch res.Reason {
+	case ErrInvalidArgs.Reason, "VALIDATOR":
+		return res.Message != "" && res.Message != ErrInvalidArgs.Message
+	}
+	return false
+}
+
+// ResponseWithErrorContext 同 ResponseWithError，Message 为请求语言的错误信息，
+// 参数错误的原始信息追加在翻译之后，如：参数错误: invalid Request.Name: value length must be at least 1 runes
+func ResponseWithErrorContext(ctx context.Context, err *errors.Error) *BizResponse {
+	res := ResponseWithError(err)
+	if err == nil {
+		return res
+	}
+
+	// 生产环境隐藏的服务端错误不使用原始错误的 metadata
+	var metadata map[string]string
+	if res.Reason == err.Reason {
+		metadata = err.Metadata
+	}
+
+	message := localizeMessage(ctx, res.Reason, res.Message, metadata)
+	if message != res.Message && hasErrorDetail(res) {
+		message += ": " + res.Message
+	}
+	res.Message = message
+	return res
+}
+
+type requestContextKey struct{}
+
+type requestContext struct {
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+// RequestContext 使 ResponseEncoder、ErrorEncoder 可以读取中间件（TryParseHeader）处理后的 context，
+// kratos 的编码器只能拿到原始的请求
+//
+//	http.NewServer(http.Filter(middleware.RequestContext()), http.ErrorEncoder(middleware.ErrorEncoder))
+func RequestContext() http.FilterFunc {
+	return func(next stdHttp.Handler) stdHttp.Handler {
+		return stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, r *stdHttp.Request) {
+			ctx := context.WithValue(r.Context(), requestContextKey{}, &requestContext{})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// saveRequestContext saves the ctx for the encoders when the RequestContext filter is used
+func saveRequestContext(ctx context.Context) {
+	if holder, ok := ctx.Value(requestContextKey{}).(*requestContext); ok {
+		holder.mu.Lock()
+		holder.ctx = ctx
+		holder.mu.Unlock()
+	}
+}
+
+// RequestContextFrom 返回中间件处理后的 context，没有使用 RequestContext 时根据请求头解析语言
+func RequestContextFrom(r *stdHttp.Request) context.Context {
+	ctx := r.Context()
+	if holder, ok := ctx.Value(requestContextKey{}).(*requestContext); ok {
+		holder.mu.Lock()
+		defer holder.mu.Unlock()
+		if holder.ctx != nil {
+			return holder.ctx
+		}
+	}
+
+	if _, ok := icontext.LanguageCodeFrom(ctx); !ok {
+		ctx = icontext.WithLanguageCode(ctx, iheader.GetLanguageCode(headerCarrier(r.Header)))
+	}
+	return ctx
+}
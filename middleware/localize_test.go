@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	stdHttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/airunny/wiki-go-tools/errors"
+	"github.com/airunny/wiki-go-tools/i18n"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/go-kratos/kratos/v2/encoding"
+	"github.com/go-kratos/kratos/v2/encoding/json"
+	kratosErrors "github.com/go-kratos/kratos/v2/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorEncoderLocalize(t *testing.T) {
+	i18n.SetLanguage(map[string]i18n.Language{
+		"en": {
+			"200101":             "{0} is out of stock",
+			"RESOURCE_NOT_FOUND": "resource not found",
+			"INVALID_ARGS":       "invalid arguments",
+		},
+		"zh-cn": {
+			"200101":       "{0}已售罄",
+			"INVALID_ARGS": "参数错误",
+			"VALIDATOR":    "参数校验失败",
+		},
+	})
+	RegisterErrorMessage("OUT_OF_STOCK", &ErrorMessage{Key: "200101", Args: []string{"goods"}})
+
+	encode := func(r *stdHttp.Request, err error, fn func(r *stdHttp.Request)) string {
+		w := httptest.NewRecorder()
+		RequestContext()(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, r *stdHttp.Request) {
+			if fn != nil {
+				fn(r)
+			}
+			ErrorEncoder(w, r, err)
+		})).ServeHTTP(w, r)
+
+		var res BizResponse
+		assert.Nil(t, encoding.GetCodec(json.Name).Unmarshal(w.Body.Bytes(), &res))
+		return res.Message
+	}
+
+	outOfStock := errors.ErrOutOfStock.WithMetadata(map[string]string{"goods": "iPhone"})
+
+	// 中间件处理后的 context
+	r := httptest.NewRequest(stdHttp.MethodGet, "/", nil)
+	assert.Equal(t, "iPhone已售罄", encode(r, outOfStock, func(r *stdHttp.Request) {
+		saveRequestContext(icontext.WithLanguageCode(r.Context(), "zh-CN"))
+	}))
+
+	// 没有经过中间件时从请求头中读取语言，没有翻译时使用英文
+	r = httptest.NewRequest(stdHttp.MethodGet, "/", nil)
+	r.Header.Set(iheader.LanguageCodeHeaderKey, "ja")
+	assert.Equal(t, "iPhone is out of stock", encode(r, outOfStock, nil))
+	assert.Equal(t, "resource not found", encode(r, errors.ErrResourceNotFound, nil))
+
+	// 没有配置多语言时保留原始的信息
+	assert.Equal(t, "price changed", encode(r, errors.ErrPriceChanged, nil))
+
+	// 参数错误保留原始的错误信息
+	assert.Equal(t, "invalid arguments", encode(r, errors.ErrBadRequest, nil))
+	r.Header.Set(iheader.LanguageCodeHeaderKey, "zh-CN")
+	assert.Equal(t, "参数错误", encode(r, errors.ErrBadRequest, nil))
+	codec := kratosErrors.BadRequest("CODEC", "body unmarshal invalid character")
+	assert.Equal(t, "参数错误: "+codec.Error(), encode(r, codec, nil))
+	validator := kratosErrors.BadRequest("VALIDATOR", "invalid Request.Name: value length must be at least 1 runes")
+	assert.Equal(t, "参数校验失败: invalid Request.Name: value length must be at least 1 runes", encode(r, validator, nil))
+}
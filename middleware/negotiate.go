@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	stdHttp "net/http"
+	"strconv"
+	"strings"
+
+	"github.com/airunny/wiki-go-tools/encoding/msgpack"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/airunny/wiki-go-tools/middleware/pb"
+	"github.com/go-kratos/kratos/v2/encoding"
+	"github.com/go-kratos/kratos/v2/encoding/json"
+	protoEncoding "github.com/go-kratos/kratos/v2/encoding/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// acceptContentTypes 支持的响应类型 => codec
+var acceptContentTypes = map[string]string{
+	iheader.ResponseContentJsonType:     json.Name,
+	iheader.ResponseContentProtobufType: protoEncoding.Name,
+	iheader.ResponseContentMsgpackType:  msgpack.Name,
+	"application/x-msgpack":             msgpack.Name,
+}
+
+// negotiate 根据 Accept 选择响应的类型，q 值相同时按 Accept 中的顺序，没有支持的类型时使用 json
+func negotiate(r *stdHttp.Request) string {
+	if r == nil {
+		return iheader.ResponseContentJsonType
+	}
+
+	var (
+		out  = iheader.ResponseContentJsonType
+		best = -1.0
+	)
+	for _, item := range strings.Split(r.Header.Get("Accept"), ",") {
+		var (
+			params    = strings.Split(item, ";")
+			mediaType = strings.ToLower(strings.TrimSpace(params[0]))
+			q         = 1.0
+		)
+
+		if _, ok := acceptContentTypes[mediaType]; !ok {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.TrimSpace(key) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > 0 && q > best {
+			out, best = mediaType, q
+		}
+	}
+	return out
+}
+
+// encodeResponse 按 Accept 编码响应，data 不是 proto message 时 protobuf 回退为 json
+func encodeResponse(r *stdHttp.Request, body interface{}) (string, []byte, error) {
+	contentType := negotiate(r)
+	switch acceptContentTypes[contentType] {
+	case protoEncoding.Name:
+		if res, ok := body.(*BizResponse); ok {
+			if data, ok, err := marshalProtoResponse(res); ok {
+				return contentType, data, err
+			}
+		}
+	case msgpack.Name:
+		data, err := encoding.GetCodec(msgpack.Name).Marshal(body)
+		return contentType, data, err
+	}
+
+	data, err := encoding.GetCodec(json.Name).Marshal(body)
+	return iheader.ResponseContentJsonType, data, err
+}
+
+// marshalProtoResponse encodes the BizResponse as the pb.BizResponse message
+func marshalProtoResponse(res *BizResponse) ([]byte, bool, error) {
+	out := &pb.BizResponse{
+		Code:    res.Code,
+		Message: res.Message,
+		Reason:  res.Reason,
+		Time:    res.Time,
+	}
+
+	if res.Data != nil {
+		msg, ok := res.Data.(proto.Message)
+		if !ok {
+			return nil, false, nil
+		}
+
+		var err error
+		if out.Data, err = anypb.New(msg); err != nil {
+			return nil, true, err
+		}
+	}
+
+	data, err := proto.Marshal(out)
+	return data, true, err
+}
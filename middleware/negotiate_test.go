@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	stdHttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/airunny/wiki-go-tools/encoding/msgpack"
+	"github.com/airunny/wiki-go-tools/errors"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/airunny/wiki-go-tools/middleware/pb"
+	"github.com/go-kratos/kratos/v2/encoding"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                       iheader.ResponseContentJsonType,
+		"*/*":                    iheader.ResponseContentJsonType,
+		"application/x-protobuf": iheader.ResponseContentProtobufType,
+		"application/x-msgpack, application/json":                 "application/x-msgpack",
+		"application/msgpack;q=0.5, application/x-protobuf;q=0.8": iheader.ResponseContentProtobufType,
+		"application/x-protobuf;q=0, text/html":                   iheader.ResponseContentJsonType,
+	}
+
+	for accept, expected := range tests {
+		r := httptest.NewRequest(stdHttp.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		assert.Equal(t, expected, negotiate(r), accept)
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	serve := func(accept string, fn func(w stdHttp.ResponseWriter, r *stdHttp.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(stdHttp.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		fn(w, r)
+		return w
+	}
+
+	reply := func(w stdHttp.ResponseWriter, r *stdHttp.Request) {
+		assert.Nil(t, ResponseEncoder(w, r, wrapperspb.String("hello")))
+	}
+
+	// protobuf
+	w := serve(iheader.ResponseContentProtobufType, reply)
+	assert.Equal(t, iheader.ResponseContentProtobufType, w.Header().Get("Content-Type"))
+	var res pb.BizResponse
+	assert.Nil(t, proto.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, int32(200), res.GetCode())
+	msg, err := res.GetData().UnmarshalNew()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", msg.(*wrapperspb.StringValue).GetValue())
+
+	// msgpack
+	w = serve(iheader.ResponseContentMsgpackType, func(w stdHttp.ResponseWriter, r *stdHttp.Request) {
+		ErrorEncoder(w, r, errors.ErrOutOfStock)
+	})
+	assert.Equal(t, iheader.ResponseContentMsgpackType, w.Header().Get("Content-Type"))
+	var out map[string]interface{}
+	assert.Nil(t, encoding.GetCodec(msgpack.Name).Unmarshal(w.Body.Bytes(), &out))
+	assert.Equal(t, "OUT_OF_STOCK", out["reason"])
+
+	// 不是 proto message 时回退为 json
+	w = serve(iheader.ResponseContentProtobufType, func(w stdHttp.ResponseWriter, r *stdHttp.Request) {
+		assert.Nil(t, ResponseEncoder(w, r, &testReply{OrderId: "1"}))
+	})
+	assert.Equal(t, iheader.ResponseContentJsonType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"order_id":"1"`)
+}
@@ -2,8 +2,18 @@ package middleware
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
+	"github.com/airunny/wiki-go-tools/country"
+	"github.com/airunny/wiki-go-tools/geo"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/go-kratos/kratos/v2/transport"
 )
 
+// CountryCodeConvert 国家code转换，AreaCode 将二字码、三字码、数字code统一转换为数字code（例如 156），
+// TwoAreaCode 转换为二字码（例如 CN）
 type CountryCodeConvert interface {
 	AreaCode(context.Context, string) (string, error)
 	TwoAreaCode(context.Context, string) (string, error)
@@ -20,12 +30,93 @@ type CustomReply interface {
 
 type Options struct {
 	convert CountryCodeConvert
+	geoIP   bool
 }
 
-type Option func(options Options)
+type Option func(*Options)
 
+// WithCountryCodeConvert 替换默认的国家code转换（基于 country 包）
 func WithCountryCodeConvert(convert CountryCodeConvert) Option {
-	return func(o Options) {
+	return func(o *Options) {
 		o.convert = convert
 	}
 }
+
+// WithoutGeoIP 请求中没有国家code时不使用 X-Forwarded-For 查询 geo ip
+func WithoutGeoIP() Option {
+	return func(o *Options) {
+		o.geoIP = false
+	}
+}
+
+func newOptions(opts ...Option) *Options {
+	o := &Options{
+		convert: countryConvert{},
+		geoIP:   true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// countryCode 返回统一格式的国家code，请求头中没有国家code时根据客户端ip查询，无法转换时保留原始值
+func (o *Options) countryCode(ctx context.Context, header transport.Header) string {
+	code := strings.TrimSpace(iheader.GetCountryCode(header))
+	if code == "" && o.geoIP {
+		code, _ = geo.GetCountryISOCode(strings.TrimSpace(iheader.GetClientIp(header)))
+	}
+
+	if code == "" || o.convert == nil {
+		return code
+	}
+
+	out, err := o.convert.AreaCode(ctx, code)
+	if err != nil || out == "" {
+		return code
+	}
+	return out
+}
+
+// countryConvert converts the country code by the country package
+type countryConvert struct{}
+
+func (countryConvert) AreaCode(_ context.Context, code string) (string, error) {
+	c, ok := country.GetCountryByCode(code)
+	if !ok {
+		return "", fmt.Errorf("unknown country code %q", code)
+	}
+	return c.CountryCode, nil
+}
+
+func (countryConvert) TwoAreaCode(_ context.Context, code string) (string, error) {
+	c, ok := country.GetCountryByCode(code)
+	if !ok {
+		return "", fmt.Errorf("unknown country code %q", code)
+	}
+	return c.TwoCharCode, nil
+}
+
+// parseHeader 将请求头解析到 context 中，TryParseHeader 与 TryParseHeaderForGin 共用
+func (o *Options) parseHeader(ctx context.Context, header transport.Header) context.Context {
+	// 客户端ip
+	ctx = icontext.WithClientIP(ctx, iheader.GetClientIp(header))
+	// 用户ID
+	ctx = icontext.WithUserId(ctx, iheader.GetUserId(header))
+	// basic data
+	ctx = icontext.WithBasicData(ctx, iheader.GetBasicData(header))
+	// 城市码
+	ctx = icontext.WithCountryCode(ctx, o.countryCode(ctx, header))
+	// 语言code
+	ctx = icontext.WithLanguageCode(ctx, iheader.GetLanguageCode(header))
+	// 偏好语言
+	ctx = icontext.WithPreferredLanguageCode(ctx, iheader.GetPreferredLanguageCode(header))
+	// wiki data center Request-Id
+	ctx = icontext.WithWikiDataCenterRequestId(ctx, iheader.GetWikiDataCenterRequestId(header))
+	// scene code
+	ctx = icontext.WithSceneCode(ctx, iheader.GetSceneCode(header))
+	// x-pwa
+	ctx = icontext.WithXPWA(ctx, iheader.GetXPwa(header))
+	// 解析一次请求信息，后续的 icontext.*From 直接读取
+	return icontext.WithRequestInfo(ctx, icontext.ParseRequestInfo(ctx))
+}
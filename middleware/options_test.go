@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/stretchr/testify/assert"
+)
+
+type testConvert struct{}
+
+func (testConvert) AreaCode(context.Context, string) (string, error) {
+	return "", errors.New("unknown")
+}
+
+func (testConvert) TwoAreaCode(context.Context, string) (string, error) {
+	return "", errors.New("unknown")
+}
+
+func TestCountryCode(t *testing.T) {
+	countryCode := func(code string, opts ...Option) string {
+		header := http.Header{}
+		header.Set(iheader.CountryCodeHeaderKey, code)
+		return newOptions(opts...).countryCode(context.Background(), headerCarrier(header))
+	}
+
+	assert.Equal(t, "156", countryCode("CN"))
+	assert.Equal(t, "156", countryCode("chn"))
+	assert.Equal(t, "156", countryCode("156"))
+	assert.Equal(t, "unknown", countryCode("unknown"))
+	// 自定义的转换失败时保留原始值
+	assert.Equal(t, "CN", countryCode("CN", WithCountryCodeConvert(testConvert{})))
+	// 没有 geo ip 数据库时保持为空
+	assert.Equal(t, "", countryCode(""))
+}
@@ -0,0 +1,163 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: response.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// BizResponse Accept 为 application/x-protobuf 时返回的响应，data 为接口返回的 proto message
+type BizResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          int32                  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	Time          int64                  `protobuf:"varint,4,opt,name=time,proto3" json:"time,omitempty"`
+	Data          *anypb.Any             `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BizResponse) Reset() {
+	*x = BizResponse{}
+	mi := &file_response_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BizResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BizResponse) ProtoMessage() {}
+
+func (x *BizResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_response_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BizResponse.ProtoReflect.Descriptor instead.
+func (*BizResponse) Descriptor() ([]byte, []int) {
+	return file_response_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *BizResponse) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *BizResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *BizResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *BizResponse) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *BizResponse) GetData() *anypb.Any {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_response_proto protoreflect.FileDescriptor
+
+const file_response_proto_rawDesc = "" +
+	"\n" +
+	"\x0eresponse.proto\x12\n" +
+	"middleware\x1a\x19google/protobuf/any.proto\"\x91\x01\n" +
+	"\vBizResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12\x12\n" +
+	"\x04time\x18\x04 \x01(\x03R\x04time\x12(\n" +
+	"\x04data\x18\x05 \x01(\v2\x14.google.protobuf.AnyR\x04dataB3Z1github.com/airunny/wiki-go-tools/middleware/pb;pbb\x06proto3"
+
+var (
+	file_response_proto_rawDescOnce sync.Once
+	file_response_proto_rawDescData []byte
+)
+
+func file_response_proto_rawDescGZIP() []byte {
+	file_response_proto_rawDescOnce.Do(func() {
+		file_response_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_response_proto_rawDesc), len(file_response_proto_rawDesc)))
+	})
+	return file_response_proto_rawDescData
+}
+
+var file_response_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_response_proto_goTypes = []any{
+	(*BizResponse)(nil), // 0: middleware.BizResponse
+	(*anypb.Any)(nil),   // 1: google.protobuf.Any
+}
+var file_response_proto_depIdxs = []int32{
+	1, // 0: middleware.BizResponse.data:type_name -> google.protobuf.Any
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_response_proto_init() }
+func file_response_proto_init() {
+	if File_response_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_response_proto_rawDesc), len(file_response_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_response_proto_goTypes,
+		DependencyIndexes: file_response_proto_depIdxs,
+		MessageInfos:      file_response_proto_msgTypes,
+	}.Build()
+	File_response_proto = out.File
+	file_response_proto_goTypes = nil
+	file_response_proto_depIdxs = nil
+}
@@ -0,0 +1,269 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/airunny/wiki-go-tools/errors"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	"github.com/go-kratos/kratos/v2/transport/http"
+	"google.golang.org/grpc/peer"
+)
+
+// RateLimitKey 返回限流的维度，返回空字符串时使用下一个 RateLimitKey
+type RateLimitKey func(ctx context.Context, operation string) string
+
+// RateLimitByUser 按认证的用户限流，需要在 Auth 之后使用，不使用客户端传入的用户ID
+func RateLimitByUser(ctx context.Context, _ string) string {
+	account, ok := icontext.AccountFrom(ctx)
+	if !ok {
+		return ""
+	}
+	return prefixKey("user", account.ID)
+}
+
+type rateLimitIPKey struct{}
+
+// RateLimitByIP 按连接的客户端ip限流，请求来自 WithRateLimitTrustedProxies 的代理时使用 X-Forwarded-For 中的ip
+func RateLimitByIP(ctx context.Context, _ string) string {
+	ip, _ := ctx.Value(rateLimitIPKey{}).(string)
+	return prefixKey("ip", ip)
+}
+
+// RateLimitByDevice 按设备ID限流，设备ID由客户端传入，只适合用于发送验证码等没有登录的场景
+func RateLimitByDevice(ctx context.Context, _ string) string {
+	deviceId, _ := icontext.DeviceIdFrom(ctx)
+	return prefixKey("device", deviceId)
+}
+
+// RateLimitByOperation 所有请求共享 operation 的限流
+func RateLimitByOperation(_ context.Context, operation string) string {
+	return prefixKey("operation", operation)
+}
+
+func prefixKey(prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	return prefix + ":" + value
+}
+
+// RateLimitRule 每个 Window 内最多 Limit 个请求，Keys 为空时先按认证的用户，没有认证时按客户端ip
+type RateLimitRule struct {
+	Limit  int
+	Window time.Duration
+	Keys   []RateLimitKey
+}
+
+type rateLimitRule struct {
+	operation string
+	rule      RateLimitRule
+}
+
+type rateLimitOptions struct {
+	rules          []*rateLimitRule
+	trustedProxies []*net.IPNet
+}
+
+type RateLimitOption func(*rateLimitOptions)
+
+// WithRateLimitTrustedProxies 可信的代理的ip或者CIDR，例如 10.0.0.0/8，请求来自可信的代理时
+// 按 X-Forwarded-For 中从右往左第一个不可信的ip限流，默认使用连接的ip；不合法的值会被忽略
+func WithRateLimitTrustedProxies(proxies ...string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		for _, proxy := range proxies {
+			switch {
+			case strings.Contains(proxy, "/"):
+			case strings.Contains(proxy, ":"):
+				proxy += "/128"
+			default:
+				proxy += "/32"
+			}
+
+			if _, ipNet, err := net.ParseCIDR(proxy); err == nil {
+				o.trustedProxies = append(o.trustedProxies, ipNet)
+			}
+		}
+	}
+}
+
+// WithRateLimitRule 设置 operation 的限流规则，operation 以 * 结尾时按前缀匹配，"*" 匹配所有请求；
+// 同时匹配多个规则时使用完全匹配的规则，其次是前缀最长的规则
+func WithRateLimitRule(operation string, rule RateLimitRule) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		if len(rule.Keys) == 0 {
+			rule.Keys = []RateLimitKey{RateLimitByUser, RateLimitByIP}
+		}
+
+		o.rules = append(o.rules, &rateLimitRule{
+			operation: operation,
+			rule:      rule,
+		})
+	}
+}
+
+func (o *rateLimitOptions) match(operation string) *rateLimitRule {
+	var (
+		out    *rateLimitRule
+		length = -1
+	)
+
+	for _, rule := range o.rules {
+		if rule.operation == operation {
+			return rule
+		}
+
+		if !matchOperation(rule.operation, operation) {
+			continue
+		}
+
+		if len(rule.operation) > length {
+			out, length = rule, len(rule.operation)
+		}
+	}
+	return out
+}
+
+// allow 检查是否限流并设置 RateLimit-* 响应头，限流器出错时不限流
+func (o *rateLimitOptions) allow(ctx context.Context, limiter RateLimiter, operation string, header transport.Header) error {
+	rule := o.match(operation)
+	if rule == nil {
+		return nil
+	}
+
+	var key string
+	for _, fn := range rule.rule.Keys {
+		if key = fn(ctx, operation); key != "" {
+			break
+		}
+	}
+
+	if key == "" {
+		return nil
+	}
+
+	res, err := limiter.Allow(ctx, rule.operation+":"+key, rule.rule.Limit, rule.rule.Window)
+	if err != nil {
+		log.Context(ctx).Errorf("rate limit %s error: %v", operation, err)
+		return nil
+	}
+
+	header.Set(iheader.RateLimitLimitHeaderKey, strconv.Itoa(res.Limit))
+	header.Set(iheader.RateLimitRemainingHeaderKey, strconv.Itoa(res.Remaining))
+	header.Set(iheader.RateLimitResetHeaderKey, seconds(res.Reset))
+	if !res.Allowed {
+		header.Set(iheader.RetryAfterHeaderKey, seconds(res.RetryAfter))
+		return errors.ErrToManyRequests
+	}
+	return nil
+}
+
+func (o *rateLimitOptions) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, proxy := range o.trustedProxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP 返回连接的ip，连接来自可信的代理时返回 X-Forwarded-For 中从右往左第一个不可信的ip
+func (o *rateLimitOptions) clientIP(remoteAddr, forwardedFor string) string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(remoteAddr))
+	if err != nil {
+		ip = strings.TrimSpace(remoteAddr)
+	}
+
+	if !o.trusted(ip) || forwardedFor == "" {
+		return ip
+	}
+
+	forwarded := strings.Split(forwardedFor, ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		value := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(value) == nil {
+			break
+		}
+
+		if ip = value; !o.trusted(value) {
+			break
+		}
+	}
+	return ip
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimit 按 operation 的规则限流，超过限制时返回 errors.ErrToManyRequests，
+// 需要在 Auth 之后使用，按用户限流时只使用认证的账号，不信任客户端传入的用户ID、ip
+//
+//	limiter, err := middleware.NewRedisRateLimiter(redisCli, "ratelimit:")
+//	middleware.RateLimit(limiter,
+//		middleware.WithRateLimitTrustedProxies("10.0.0.0/8"),
+//		middleware.WithRateLimitRule("*", middleware.RateLimitRule{Limit: 100, Window: time.Minute}),
+//		middleware.WithRateLimitRule("/api.user.v1.User/SendCode", middleware.RateLimitRule{
+//			Limit:  1,
+//			Window: time.Minute,
+//			Keys:   []middleware.RateLimitKey{middleware.RateLimitByDevice, middleware.RateLimitByIP},
+//		}),
+//	)
+func RateLimit(limiter RateLimiter, opts ...RateLimitOption) middleware.Middleware {
+	o := &rateLimitOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			if r, ok := http.RequestFromServerContext(ctx); ok {
+				ctx = context.WithValue(ctx, rateLimitIPKey{}, o.clientIP(r.RemoteAddr, r.Header.Get(iheader.ForwardForHeaderKey)))
+			} else if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+				ctx = context.WithValue(ctx, rateLimitIPKey{}, o.clientIP(p.Addr.String(), ""))
+			}
+
+			if err := o.allow(ctx, limiter, tr.Operation(), tr.ReplyHeader()); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
+// RateLimitForGin gin 的限流中间件，operation 为路由的路径（gin.Context.FullPath）
+func RateLimitForGin(limiter RateLimiter, opts ...RateLimitOption) gin.HandlerFunc {
+	o := &rateLimitOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), rateLimitIPKey{}, o.clientIP(c.Request.RemoteAddr, c.GetHeader(iheader.ForwardForHeaderKey)))
+		err := o.allow(ctx, limiter, c.FullPath(), headerCarrier(c.Writer.Header()))
+		if err != nil {
+			JSONError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	stdHttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/airunny/wiki-go-tools/errors"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/airunny/wiki-go-tools/token"
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	o := &rateLimitOptions{}
+	for _, opt := range []RateLimitOption{
+		WithRateLimitRule("*", RateLimitRule{Limit: 100, Window: time.Minute}),
+		WithRateLimitRule("/api.user.v1.User/*", RateLimitRule{Limit: 2, Window: time.Minute}),
+		WithRateLimitRule("/api.user.v1.User/SendCode", RateLimitRule{
+			Limit:  1,
+			Window: time.Hour,
+			Keys:   []RateLimitKey{RateLimitByDevice},
+		}),
+	} {
+		opt(o)
+	}
+
+	assert.Equal(t, "*", o.match("/api.news.v1.News/List").operation)
+	assert.Equal(t, "/api.user.v1.User/*", o.match("/api.user.v1.User/Get").operation)
+	assert.Equal(t, "/api.user.v1.User/SendCode", o.match("/api.user.v1.User/SendCode").operation)
+
+	var (
+		limiter = NewLocalRateLimiter()
+		ctx     = icontext.WithAccount(context.Background(), &token.Account{ID: "user-1"})
+		allow   = func(ctx context.Context, operation string) (stdHttp.Header, error) {
+			header := stdHttp.Header{}
+			return header, o.allow(ctx, limiter, operation, headerCarrier(header))
+		}
+	)
+
+	header, err := allow(ctx, "/api.user.v1.User/Get")
+	assert.Nil(t, err)
+	assert.Equal(t, "2", header.Get(iheader.RateLimitLimitHeaderKey))
+	assert.Equal(t, "1", header.Get(iheader.RateLimitRemainingHeaderKey))
+
+	_, err = allow(ctx, "/api.user.v1.User/Get")
+	assert.Nil(t, err)
+
+	header, err = allow(ctx, "/api.user.v1.User/Get")
+	assert.True(t, errors.ErrToManyRequests.Is(err))
+	assert.Equal(t, "0", header.Get(iheader.RateLimitRemainingHeaderKey))
+	assert.Equal(t, "30", header.Get(iheader.RetryAfterHeaderKey))
+
+	// 其他用户不受影响
+	_, err = allow(icontext.WithAccount(context.Background(), &token.Account{ID: "user-2"}), "/api.user.v1.User/Get")
+	assert.Nil(t, err)
+
+	// 客户端传入的用户ID不生效，没有认证时按连接的ip限流
+	ctx = icontext.WithUserId(context.WithValue(context.Background(), rateLimitIPKey{}, "1.1.1.1"), "user-1")
+	header, err = allow(ctx, "/api.user.v1.User/Get")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", header.Get(iheader.RateLimitRemainingHeaderKey))
+
+	// 没有设备ID时不限流
+	for i := 0; i < 3; i++ {
+		header, err = allow(ctx, "/api.user.v1.User/SendCode")
+		assert.Nil(t, err)
+		assert.Empty(t, header.Get(iheader.RateLimitLimitHeaderKey))
+	}
+}
+
+func TestRateLimitClientIP(t *testing.T) {
+	o := &rateLimitOptions{}
+	WithRateLimitTrustedProxies("10.0.0.0/8", "192.168.1.1", "invalid")(o)
+	assert.Len(t, o.trustedProxies, 2)
+
+	tests := []struct {
+		remoteAddr   string
+		forwardedFor string
+		ip           string
+	}{
+		// 不可信的连接不使用 X-Forwarded-For
+		{remoteAddr: "1.1.1.1:1234", forwardedFor: "2.2.2.2", ip: "1.1.1.1"},
+		{remoteAddr: "10.0.0.1:1234", forwardedFor: "", ip: "10.0.0.1"},
+		{remoteAddr: "10.0.0.1:1234", forwardedFor: "2.2.2.2", ip: "2.2.2.2"},
+		// 客户端伪造的 X-Forwarded-For 在左边
+		{remoteAddr: "10.0.0.1:1234", forwardedFor: "3.3.3.3, 2.2.2.2, 192.168.1.1", ip: "2.2.2.2"},
+		{remoteAddr: "10.0.0.1:1234", forwardedFor: "invalid, 10.0.0.2", ip: "10.0.0.2"},
+		{remoteAddr: "192.168.1.1:1234", forwardedFor: "2.2.2.2", ip: "2.2.2.2"},
+		{remoteAddr: "192.168.1.2:1234", forwardedFor: "2.2.2.2", ip: "192.168.1.2"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.ip, o.clientIP(test.remoteAddr, test.forwardedFor), test.remoteAddr+" "+test.forwardedFor)
+	}
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	s := miniredis.RunT(t)
+	cli := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer cli.Close()
+
+	// 窗口使用 redis 的时间，与本机的时间无关
+	now := time.Now().Add(-time.Hour)
+	s.SetTime(now)
+
+	limiter, err := NewRedisRateLimiter(cli, "ratelimit:")
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		res, err := limiter.Allow(ctx, "user:1", 2, time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 1-i, res.Remaining)
+		assert.True(t, res.Reset > 0 && res.Reset <= time.Minute)
+	}
+
+	res, err := limiter.Allow(ctx, "user:1", 2, time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= time.Minute)
+
+	// 被拒绝的请求不计入窗口
+	members, err := cli.ZCard(ctx, "ratelimit:user:1").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), members)
+	assert.True(t, s.TTL("ratelimit:user:1") > 0)
+
+	// redis 的时间经过一个窗口后恢复
+	s.SetTime(now.Add(time.Minute + time.Second))
+	res, err = limiter.Allow(ctx, "user:1", 2, time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+
+	// 其他 key 不受影响
+	res, err = limiter.Allow(ctx, "user:2", 2, time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, res.Allowed)
+
+	_, err = limiter.Allow(ctx, "user:1", 0, time.Minute)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitResult 一次限流检查的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // 剩余的请求数恢复的时间
+	RetryAfter time.Duration // 被限流时下一个请求可以通过的时间
+}
+
+// RateLimiter 限流器，key 为限流的维度，例如 operation + 用户ID
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error)
+}
+
+// NewLocalRateLimiter 单机令牌桶，每个 window 恢复 limit 个令牌，桶的容量为 limit
+func NewLocalRateLimiter() RateLimiter {
+	return &localRateLimiter{
+		buckets: make(map[string]*localBucket),
+	}
+}
+
+type localBucket struct {
+	limiter  *rate.Limiter
+	window   time.Duration
+	lastSeen time.Time
+}
+
+type localRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*localBucket
+	lastSweep time.Time
+}
+
+func (l *localRateLimiter) Allow(_ context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	if limit <= 0 || window <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %d/%v", limit, window)
+	}
+
+	var (
+		now   = time.Now()
+		every = rate.Limit(float64(limit) / window.Seconds())
+	)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	bucket, ok := l.buckets[key]
+	if !ok || bucket.limiter.Burst() != limit || bucket.limiter.Limit() != every {
+		bucket = &localBucket{
+			limiter: rate.NewLimiter(every, limit),
+			window:  window,
+		}
+		l.buckets[key] = bucket
+	}
+	bucket.lastSeen = now
+
+	allowed := bucket.limiter.AllowN(now, 1)
+	tokens := bucket.limiter.TokensAt(now)
+	return &RateLimitResult{
+		Allowed:    allowed,
+		Limit:      limit,
+		Remaining:  int(math.Max(0, math.Floor(tokens))),
+		Reset:      tokensDuration(float64(limit)-tokens, every),
+		RetryAfter: tokensDuration(1-tokens, every),
+	}, nil
+}
+
+// tokensDuration returns the time to refill n tokens
+func tokensDuration(n float64, every rate.Limit) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n / float64(every) * float64(time.Second))
+}
+
+// sweep 删除超过一个窗口没有请求的桶，这些桶已经恢复满，与新建的桶一致
+func (l *localRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > bucket.window {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// slidingWindowCommand 使用有序集合记录窗口内每次请求的时间，返回 {allowed, remaining, reset(ms)}；
+// 时间使用 redis 的 TIME，避免各个实例的时钟偏差影响共享的窗口，redis 5 之前需要 replicate_commands 才能在 TIME 之后写入
+const slidingWindowCommand = `if redis.replicate_commands then
+    redis.replicate_commands()
+end
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+local allowed = 0
+if count < limit then
+    redis.call("ZADD", KEYS[1], now, ARGV[3])
+    count = count + 1
+    allowed = 1
+end
+redis.call("PEXPIRE", KEYS[1], window)
+local reset = window
+local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+if oldest[2] then
+    reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}`
+
+var slidingWindowScript = redis.NewScript(slidingWindowCommand)
+
+// NewRedisRateLimiter 基于 redis 的滑动窗口，多个实例共享限流，prefix 为 redis key 的前缀
+func NewRedisRateLimiter(cli *redis.Client, prefix string) (RateLimiter, error) {
+	if cli == nil {
+		return nil, errors.New("empty cli")
+	}
+
+	return &redisRateLimiter{
+		redisCli: cli,
+		prefix:   prefix,
+	}, nil
+}
+
+type redisRateLimiter struct {
+	redisCli *redis.Client
+	prefix   string
+}
+
+func (l *redisRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	if limit <= 0 || window <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %d/%v", limit, window)
+	}
+
+	values, err := slidingWindowScript.Run(ctx, l.redisCli, []string{l.prefix + key},
+		window.Milliseconds(),
+		limit,
+		fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Int63()),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit result %v", values)
+	}
+
+	// 滑动窗口中最早的请求过期后即可通过
+	reset := time.Duration(values[2]) * time.Millisecond
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  int(values[1]),
+		Reset:      reset,
+		RetryAfter: reset,
+	}, nil
+}
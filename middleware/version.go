@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/airunny/wiki-go-tools/errors"
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/airunny/wiki-go-tools/iheader"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+)
+
+type versionOptions struct {
+	flagOnly bool
+}
+
+type VersionOption func(*versionOptions)
+
+// WithVersionFlagOnly 不拒绝低版本的请求，只在 context 中标记（见 icontext.UpgradeRequiredFrom）并返回 X-Upgrade-Required 头
+func WithVersionFlagOnly() VersionOption {
+	return func(o *versionOptions) {
+		o.flagOnly = true
+	}
+}
+
+// MinAppVersion 拒绝低于平台最低版本的请求，返回 errors.ErrUpgradeRequired，metadata 中的 min_version 为最低版本
+// 需要在 TryParseHeader 之后使用，没有版本或版本无法解析的请求不做限制
+func MinAppVersion(gate *icontext.VersionGate, opts ...VersionOption) middleware.Middleware {
+	o := &versionOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			platform, ok := icontext.PlatformFrom(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			version, ok := icontext.VersionFrom(ctx)
+			if !ok || gate.Allow(platform, version) {
+				return handler(ctx, req)
+			}
+
+			minVersion, _ := gate.MinVersion(platform)
+			if tr, ok := transport.FromServerContext(ctx); ok {
+				tr.ReplyHeader().Set(iheader.UpgradeRequiredHeaderKey, minVersion.String())
+			}
+
+			if o.flagOnly {
+				return handler(icontext.WithUpgradeRequired(ctx, minVersion), req)
+			}
+
+			return nil, errors.ErrUpgradeRequired.WithMetadata(map[string]string{
+				"platform":    string(platform),
+				"version":     version.String(),
+				"min_version": minVersion.String(),
+			})
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package registry
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/go-kratos/kratos/v2/selector"
+)
+
+// MetadataKeyEnv is the metadata key of the environment which the instance belongs to,
+// instances without it are treated as stable instances.
+const MetadataKeyEnv = "env"
+
+// CanaryNodeFilter routes the requests marked by the `Route-Wsc-Val` header to the instances of the same environment.
+// Example header: Route-Wsc-Val: {"env": "gray"} => instances with metadata env=gray
+// Requests without the mark, or without any matching instance, are routed to the stable instances,
+// except the percentage of WithCanaryWeight which is routed to the weighted environment.
+func CanaryNodeFilter(opts ...CanaryOption) selector.NodeFilter {
+	o := defaultCanaryOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(ctx context.Context, nodes []selector.Node) []selector.Node {
+		var (
+			env, _  = icontext.GetWSCValue(ctx, o.wscKey)
+			matched = make([]selector.Node, 0, len(nodes))
+			stable  = make([]selector.Node, 0, len(nodes))
+		)
+
+		if env == "" && o.weight > 0 && rand.Intn(100) < o.weight {
+			env = o.weightEnv
+		}
+
+		for _, node := range nodes {
+			value := node.Metadata()[o.metadataKey]
+			if value == "" {
+				stable = append(stable, node)
+				continue
+			}
+
+			if env != "" && value == env {
+				matched = append(matched, node)
+			}
+		}
+
+		if len(matched) > 0 {
+			return matched
+		}
+
+		if len(stable) > 0 {
+			return stable
+		}
+		return nodes
+	}
+}
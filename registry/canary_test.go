@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/airunny/wiki-go-tools/icontext"
+	"github.com/go-kratos/kratos/v2/selector"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanaryNodeFilter(t *testing.T) {
+	newNodes := func(envs ...string) []selector.Node {
+		nodes := make([]selector.Node, 0, len(envs))
+		for i, env := range envs {
+			metadata := map[string]string{}
+			if env != "" {
+				metadata[MetadataKeyEnv] = env
+			}
+			nodes = append(nodes, selector.NewNode("grpc", string(rune('a'+i)), newTestInstance(string(rune('a'+i)), "", metadata)))
+		}
+		return nodes
+	}
+
+	var (
+		plain = context.Background()
+		gray  = icontext.WithWSC(context.Background(), `{"env":"gray"}`)
+		blue  = icontext.WithWSC(context.Background(), `{"env":"blue"}`)
+	)
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		opts   []CanaryOption
+		envs   []string
+		expect []string
+	}{
+		{name: "stable", ctx: plain, envs: []string{"", "gray", ""}, expect: []string{"a", "c"}},
+		{name: "canary", ctx: gray, envs: []string{"", "gray", "blue"}, expect: []string{"b"}},
+		// 没有匹配的灰度实例时使用稳定实例
+		{name: "fallback to stable", ctx: blue, envs: []string{"", "gray"}, expect: []string{"a"}},
+		// 只有灰度实例时使用所有实例
+		{name: "fallback to all", ctx: plain, envs: []string{"gray", "blue"}, expect: []string{"a", "b"}},
+		{name: "weight 100", ctx: plain, opts: []CanaryOption{WithCanaryWeight("gray", 100)}, envs: []string{"", "gray"}, expect: []string{"b"}},
+		{name: "weight 0", ctx: plain, opts: []CanaryOption{WithCanaryWeight("gray", 0)}, envs: []string{"", "gray"}, expect: []string{"a"}},
+		// 有灰度标记的请求不受权重影响
+		{name: "weight with mark", ctx: blue, opts: []CanaryOption{WithCanaryWeight("gray", 100)}, envs: []string{"", "gray", "blue"}, expect: []string{"c"}},
+		{
+			name:   "custom keys",
+			ctx:    icontext.WithWSC(context.Background(), `{"lane":"gray"}`),
+			opts:   []CanaryOption{WithCanaryWSCKey("lane"), WithCanaryMetadataKey(MetadataKeyEnv)},
+			envs:   []string{"", "gray"},
+			expect: []string{"b"},
+		},
+	}
+	for _, test := range tests {
+		nodes := CanaryNodeFilter(test.opts...)(test.ctx, newNodes(test.envs...))
+		assert.Equal(t, test.expect, nodeIDs(nodes), test.name)
+	}
+
+	// 按权重放量
+	var (
+		filter = CanaryNodeFilter(WithCanaryWeight("gray", 30))
+		canary int
+	)
+	for i := 0; i < 1000; i++ {
+		if nodeIDs(filter(plain, newNodes("", "gray")))[0] == "b" {
+			canary++
+		}
+	}
+	assert.InDelta(t, 300, canary, 100)
+}
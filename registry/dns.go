@@ -0,0 +1,136 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"      // nolint
+	"github.com/go-kratos/kratos/v2/registry" // nolint
+)
+
+// DNSRegistry implements service discovery based on DNS, it is used for local development such as docker-compose.
+// SRV records `_{scheme}._tcp.{name}` are queried first, e.g. `_grpc._tcp.user` => grpc://host:port,
+// when there is no SRV record, the A records of `{name}` are used with the default port of each scheme.
+// The records are maintained by the DNS server, so Register and Deregister do nothing.
+type DNSRegistry struct {
+	resolver *net.Resolver
+	interval time.Duration
+	ports    map[string]int
+	schemes  []string
+	stopCh   chan struct{}
+	l        *log.Helper
+}
+
+// NewDNSRegistry is used to initialize the DNSRegistry
+func NewDNSRegistry(logger log.Logger, opts ...DNSOption) *DNSRegistry {
+	o := defaultDNSOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	schemes := make([]string, 0, len(o.ports))
+	for scheme := range o.ports {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return &DNSRegistry{
+		resolver: o.resolver,
+		interval: o.interval,
+		ports:    o.ports,
+		schemes:  schemes,
+		stopCh:   make(chan struct{}),
+		l:        log.NewHelper(logger),
+	}
+}
+
+// Register does nothing, the records are maintained by the DNS server
+func (s *DNSRegistry) Register(_ context.Context, _ *registry.ServiceInstance) error {
+	return nil
+}
+
+// Deregister does nothing, the records are maintained by the DNS server
+func (s *DNSRegistry) Deregister(_ context.Context, _ *registry.ServiceInstance) error {
+	return nil
+}
+
+// GetService resolves the service instances according to the service name.
+func (s *DNSRegistry) GetService(ctx context.Context, name string) ([]*registry.ServiceInstance, error) {
+	var endpoints []string
+	for _, scheme := range s.schemes {
+		_, records, err := s.resolver.LookupSRV(ctx, scheme, "tcp", name)
+		if err != nil {
+			continue
+		}
+
+		for _, record := range records {
+			endpoints = append(endpoints, fmt.Sprintf("%s://%s:%d", scheme, strings.TrimSuffix(record.Target, "."), record.Port))
+		}
+	}
+
+	if len(endpoints) == 0 {
+		hosts, err := s.resolver.LookupHost(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, host := range hosts {
+			for _, scheme := range s.schemes {
+				endpoints = append(endpoints, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(s.ports[scheme]))))
+			}
+		}
+	}
+	return instancesFromEndpoints(name, endpoints), nil
+}
+
+// Watch polls the DNS records and creates a watcher according to the service name.
+func (s *DNSRegistry) Watch(ctx context.Context, name string) (registry.Watcher, error) {
+	iter := NewIterator(ctx, s.stopCh, s.l)
+	go s.poll(ctx, name, iter)
+	return iter, nil
+}
+
+// Close is used to close the DNSRegistry
+func (s *DNSRegistry) Close() {
+	select {
+	case <-s.stopCh:
+	default:
+		close(s.stopCh)
+	}
+}
+
+func (s *DNSRegistry) poll(ctx context.Context, name string, iter *Iterator) {
+	var (
+		ticker = time.NewTicker(s.interval)
+		last   []*registry.ServiceInstance
+		first  = true
+	)
+	defer ticker.Stop()
+
+	for {
+		instances, err := s.GetService(ctx, name)
+		if err != nil {
+			s.l.Errorf("lookup service[%v] err:%v", name, err)
+			iter.update(nil, err)
+			first = true
+		} else if first || !instancesEqual(last, instances) {
+			iter.update(instances, nil)
+			last, first = instances, false
+		}
+
+		select {
+		case <-ticker.C:
+		case <-iter.stopCh:
+			return
+		case <-s.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
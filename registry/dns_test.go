@@ -0,0 +1,119 @@
+package registry
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testDNSServer answers the SRV records of _grpc._tcp.user and the A records of order, other names do not exist
+func testDNSServer(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		var length uint16
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+
+		var req dnsmessage.Message
+		if err := req.Unpack(data); err != nil || len(req.Questions) == 0 {
+			return
+		}
+
+		var (
+			question = req.Questions[0]
+			res      = dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: req.ID, Response: true, Authoritative: true, RCode: dnsmessage.RCodeNameError},
+				Questions: req.Questions,
+			}
+			header = dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET, TTL: 60}
+		)
+
+		switch {
+		case question.Name.String() == "_grpc._tcp.user." && question.Type == dnsmessage.TypeSRV:
+			res.RCode = dnsmessage.RCodeSuccess
+			res.Answers = []dnsmessage.Resource{
+				{Header: header, Body: &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("user-1."), Port: 9000}},
+				{Header: header, Body: &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("user-2."), Port: 9000}},
+			}
+		case question.Name.String() == "order.":
+			res.RCode = dnsmessage.RCodeSuccess
+			if question.Type == dnsmessage.TypeA {
+				res.Answers = []dnsmessage.Resource{
+					{Header: header, Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}}},
+				}
+			}
+		}
+
+		out, err := res.Pack()
+		if err != nil {
+			return
+		}
+
+		if err = binary.Write(conn, binary.BigEndian, uint16(len(out))); err != nil {
+			return
+		}
+
+		if _, err = conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+func newTestDNSRegistry() *DNSRegistry {
+	// net.Pipe 不是 PacketConn，resolver 使用 tcp 的格式
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go testDNSServer(server)
+			return client, nil
+		},
+	}
+	return NewDNSRegistry(log.DefaultLogger, WithDNSResolver(resolver), WithDNSInterval(10*time.Millisecond))
+}
+
+func TestDNSRegistry(t *testing.T) {
+	r := newTestDNSRegistry()
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// SRV 记录
+	users, err := r.GetService(ctx, "user")
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	assert.ElementsMatch(t, []string{"user-1", "user-2"}, []string{users[0].ID, users[1].ID})
+	assert.ElementsMatch(t, []string{"grpc://user-1:9000", "grpc://user-2:9000"}, append(users[0].Endpoints, users[1].Endpoints...))
+
+	// 没有 SRV 记录时使用 A 记录以及默认端口
+	orders, err := r.GetService(ctx, "order")
+	assert.Nil(t, err)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, "10.0.0.1", orders[0].ID)
+	assert.ElementsMatch(t, []string{"grpc://10.0.0.1:9000", "http://10.0.0.1:8000"}, orders[0].Endpoints)
+
+	_, err = r.GetService(ctx, "unknown")
+	assert.NotNil(t, err)
+
+	w, err := r.Watch(ctx, "user")
+	assert.Nil(t, err)
+	instances, err := w.Next()
+	assert.Nil(t, err)
+	assert.Len(t, instances, 2)
+	assert.Nil(t, w.Stop())
+}
@@ -0,0 +1,394 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kratos/kratos/v2/log"      // nolint
+	"github.com/go-kratos/kratos/v2/registry" // nolint
+	"gopkg.in/yaml.v3"
+)
+
+// FileRegistry implements service discovery based on a local yaml file, it is used for local development
+// such as docker-compose, so that the same `discovery:///name` endpoints can be used as production.
+//
+// Example file:
+/*
+user:
+  - http://127.0.0.1:8000
+  - grpc://127.0.0.1:9000
+order:
+  - id: order-1
+    version: v3.5.0
+    metadata:
+      zone: sh001
+    endpoints:
+      - http://order:8000
+      - grpc://order:9000
+*/
+// Plain endpoints of the same host are merged into one instance.
+// The file is reloaded when it changes, Register/Deregister rewrite the file (comments are not kept).
+type FileRegistry struct {
+	path     string
+	mu       sync.RWMutex
+	services map[string][]*registry.ServiceInstance
+	watchers map[string]map[*Iterator]struct{}
+	writeMu  sync.Mutex
+	watcher  *fsnotify.Watcher
+	stopCh   chan struct{}
+	l        *log.Helper
+}
+
+// NewFileRegistry is used to initialize the FileRegistry, the file is allowed to not exist yet
+func NewFileRegistry(path string, logger log.Logger) (*FileRegistry, error) {
+	if path == "" {
+		return nil, errors.New("empty registry file path")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// 监听目录，兼容编辑器先写临时文件再重命名的保存方式
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	s := &FileRegistry{
+		path:     path,
+		services: map[string][]*registry.ServiceInstance{},
+		watchers: map[string]map[*Iterator]struct{}{},
+		watcher:  watcher,
+		stopCh:   make(chan struct{}),
+		l:        log.NewHelper(logger),
+	}
+
+	if err = s.reload(); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	go s.watch()
+	return s, nil
+}
+
+// Register adds or replaces the instance in the file
+func (s *FileRegistry) Register(_ context.Context, service *registry.ServiceInstance) error {
+	return s.modify(func(entries fileEntries) {
+		var (
+			id        = fileInstanceID(service)
+			instances = make([]*fileInstance, 0, len(entries[service.Name])+1)
+		)
+
+		for _, instance := range entries[service.Name] {
+			if fileInstanceID(instance.toServiceInstance(service.Name)) == id {
+				continue
+			}
+			instances = append(instances, instance)
+		}
+
+		entries[service.Name] = append(instances, &fileInstance{
+			ID:        service.ID,
+			Version:   service.Version,
+			Metadata:  service.Metadata,
+			Endpoints: service.Endpoints,
+		})
+	})
+}
+
+// Deregister removes the instance from the file
+func (s *FileRegistry) Deregister(_ context.Context, service *registry.ServiceInstance) error {
+	return s.modify(func(entries fileEntries) {
+		var (
+			id        = fileInstanceID(service)
+			instances = make([]*fileInstance, 0, len(entries[service.Name]))
+		)
+
+		for _, instance := range entries[service.Name] {
+			if fileInstanceID(instance.toServiceInstance(service.Name)) == id {
+				continue
+			}
+			instances = append(instances, instance)
+		}
+
+		if len(instances) == 0 {
+			delete(entries, service.Name)
+			return
+		}
+		entries[service.Name] = instances
+	})
+}
+
+// GetService return the service instances in memory according to the service name.
+func (s *FileRegistry) GetService(_ context.Context, name string) ([]*registry.ServiceInstance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.services[name], nil
+}
+
+// Watch creates a watcher according to the service name.
+func (s *FileRegistry) Watch(ctx context.Context, name string) (registry.Watcher, error) {
+	iter := NewIterator(ctx, s.stopCh, s.l)
+	iter.onStop = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.watchers[name], iter)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchers[name] == nil {
+		s.watchers[name] = map[*Iterator]struct{}{}
+	}
+	s.watchers[name][iter] = struct{}{}
+	iter.update(s.services[name], nil)
+
+	// ctx 结束时从 watchers 中移除
+	go iter.stopOnDone()
+	return iter, nil
+}
+
+// Close is used to close the FileRegistry
+func (s *FileRegistry) Close() error {
+	select {
+	case <-s.stopCh:
+		return nil
+	default:
+		close(s.stopCh)
+	}
+	return s.watcher.Close()
+}
+
+func (s *FileRegistry) watch() {
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(event.Name) != filepath.Clean(s.path) {
+				continue
+			}
+
+			if err := s.reload(); err != nil {
+				s.l.Errorf("reload registry file[%v] err:%v", s.path, err)
+			}
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.l.Errorf("watch registry file[%v] err:%v", s.path, err)
+		}
+	}
+}
+
+// reload reads the file and notifies the watchers of the changed services,
+// the previous instances are kept when the file is invalid.
+func (s *FileRegistry) reload() error {
+	entries, err := readFileEntries(s.path)
+	if err != nil {
+		return err
+	}
+
+	services := make(map[string][]*registry.ServiceInstance, len(entries))
+	for name, instances := range entries {
+		services[name] = fileInstancesToServiceInstances(name, instances)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range mergeKeys(s.services, services) {
+		if instancesEqual(s.services[name], services[name]) {
+			continue
+		}
+
+		for iter := range s.watchers[name] {
+			iter.update(services[name], nil)
+		}
+	}
+	s.services = services
+	return nil
+}
+
+func (s *FileRegistry) modify(fn func(entries fileEntries)) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	entries, err := readFileEntries(s.path)
+	if err != nil {
+		return err
+	}
+
+	fn(entries)
+	data, err := yaml.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(s.path, data, 0644); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+// //////////// File Format ////////////
+
+type fileEntries map[string][]*fileInstance
+
+type fileInstance struct {
+	ID        string            `yaml:"id,omitempty"`
+	Version   string            `yaml:"version,omitempty"`
+	Metadata  map[string]string `yaml:"metadata,omitempty"`
+	Endpoints []string          `yaml:"endpoints,omitempty"`
+}
+
+// UnmarshalYAML accepts a plain endpoint string as well as an instance object
+func (f *fileInstance) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		f.Endpoints = []string{value.Value}
+		return nil
+	}
+
+	type plain fileInstance
+	return value.Decode((*plain)(f))
+}
+
+func (f *fileInstance) toServiceInstance(name string) *registry.ServiceInstance {
+	metadata := make(map[string]string, len(f.Metadata))
+	for k, v := range f.Metadata {
+		metadata[k] = v
+	}
+
+	return &registry.ServiceInstance{
+		ID:        f.ID,
+		Name:      name,
+		Version:   f.Version,
+		Metadata:  metadata,
+		Endpoints: f.Endpoints,
+	}
+}
+
+func readFileEntries(path string) (fileEntries, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fileEntries{}, nil
+		}
+		return nil, err
+	}
+
+	entries := fileEntries{}
+	if err = yaml.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// fileInstancesToServiceInstances merges the plain endpoints of the same host into one instance
+func fileInstancesToServiceInstances(name string, instances []*fileInstance) []*registry.ServiceInstance {
+	var (
+		out    = make([]*registry.ServiceInstance, 0, len(instances))
+		plains []string
+	)
+
+	for _, instance := range instances {
+		if instance.ID == "" && instance.Version == "" && len(instance.Metadata) == 0 {
+			plains = append(plains, instance.Endpoints...)
+			continue
+		}
+
+		out = append(out, instance.toServiceInstance(name))
+	}
+	return append(out, instancesFromEndpoints(name, plains)...)
+}
+
+func fileInstanceID(instance *registry.ServiceInstance) string {
+	if instance.ID != "" {
+		return instance.ID
+	}
+
+	for _, endpoint := range instance.Endpoints {
+		if u, err := url.Parse(endpoint); err == nil {
+			return u.Hostname()
+		}
+	}
+	return ""
+}
+
+// //////////// Helper Func ////////////
+
+// instancesFromEndpoints groups the endpoints by host, each host is an instance
+func instancesFromEndpoints(name string, endpoints []string) []*registry.ServiceInstance {
+	var (
+		hosts  []string
+		groups = make(map[string][]string)
+	)
+
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			continue
+		}
+
+		host := u.Hostname()
+		if _, ok := groups[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		groups[host] = append(groups[host], endpoint)
+	}
+
+	out := make([]*registry.ServiceInstance, 0, len(hosts))
+	for _, host := range hosts {
+		out = append(out, &registry.ServiceInstance{
+			ID:        host,
+			Name:      name,
+			Metadata:  map[string]string{},
+			Endpoints: groups[host],
+		})
+	}
+	return out
+}
+
+func mergeKeys(a, b map[string][]*registry.ServiceInstance) map[string]struct{} {
+	out := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		out[k] = struct{}{}
+	}
+	for k := range b {
+		out[k] = struct{}{}
+	}
+	return out
+}
+
+func instancesEqual(a, b []*registry.ServiceInstance) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	aStr, bStr := sortedInstancesString(a), sortedInstancesString(b)
+	return aStr == bStr
+}
+
+func sortedInstancesString(instances []*registry.ServiceInstance) string {
+	values := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		// encoding/json 会对 map 的 key 排序，保证结果稳定
+		value, _ := json.Marshal(instance)
+		values = append(values, string(value))
+	}
+	sort.Strings(values)
+	return strings.Join(values, "\n")
+}
@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFile replaces the file by renaming, os.WriteFile truncates the file first and the registry may read an empty file
+func writeFile(t *testing.T, path, content string) {
+	tmp := path + ".tmp"
+	assert.Nil(t, os.WriteFile(tmp, []byte(content), 0644))
+	assert.Nil(t, os.Rename(tmp, path))
+}
+
+// waitInstances waits until the watcher returns n instances
+func waitInstances(t *testing.T, w registry.Watcher, n int) []*registry.ServiceInstance {
+	type result struct {
+		instances []*registry.ServiceInstance
+		err       error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		for {
+			instances, err := w.Next()
+			if err != nil || len(instances) == n {
+				ch <- result{instances: instances, err: err}
+				return
+			}
+		}
+	}()
+
+	select {
+	case res := <-ch:
+		assert.Nil(t, res.err)
+		return res.instances
+	case <-time.After(5 * time.Second):
+		t.Fatalf("wait for %d instances timeout", n)
+		return nil
+	}
+}
+
+func TestFileRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.yaml")
+	writeFile(t, path, `
+user:
+  - http://127.0.0.1:8000
+  - grpc://127.0.0.1:9000
+order:
+  - id: order-1
+    version: v3.5.0
+    metadata:
+      zone: sh001
+    endpoints:
+      - http://order:8000
+`)
+
+	r, err := NewFileRegistry(path, log.DefaultLogger)
+	assert.Nil(t, err)
+	defer r.Close()
+
+	// 相同 host 的 endpoint 合并为一个实例
+	users, err := r.GetService(context.Background(), "user")
+	assert.Nil(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "127.0.0.1", users[0].ID)
+	assert.Equal(t, []string{"http://127.0.0.1:8000", "grpc://127.0.0.1:9000"}, users[0].Endpoints)
+
+	orders, err := r.GetService(context.Background(), "order")
+	assert.Nil(t, err)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, "v3.5.0", orders[0].Version)
+	assert.Equal(t, "sh001", orders[0].Metadata["zone"])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := r.Watch(ctx, "user")
+	assert.Nil(t, err)
+	waitInstances(t, w, 1)
+
+	// 文件变更后通知 watcher
+	writeFile(t, path, `
+user:
+  - http://127.0.0.1:8000
+  - http://127.0.0.2:8000
+`)
+	waitInstances(t, w, 2)
+
+	// 文件内容不合法时保留之前的实例
+	writeFile(t, path, "user: [\n")
+	time.Sleep(100 * time.Millisecond)
+	users, err = r.GetService(context.Background(), "user")
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+
+	// Register、Deregister 修改文件
+	writeFile(t, path, "user:\n  - http://127.0.0.1:8000\n")
+	waitInstances(t, w, 1)
+
+	instance := &registry.ServiceInstance{ID: "user-2", Name: "user", Endpoints: []string{"http://127.0.0.3:8000"}}
+	assert.Nil(t, r.Register(context.Background(), instance))
+	waitInstances(t, w, 2)
+
+	assert.Nil(t, r.Deregister(context.Background(), instance))
+	waitInstances(t, w, 1)
+
+	// ctx 结束时移除 watcher
+	cancel()
+	assert.Eventually(t, func() bool {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return len(r.watchers["user"]) == 0
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestNewLocalDiscovery(t *testing.T) {
+	localDiscovery = nil
+	t.Cleanup(func() { localDiscovery = nil })
+
+	// 创建失败时不缓存错误
+	t.Setenv(FileEnvName, filepath.Join(t.TempDir(), "not_exist", "registry.yaml"))
+	_, err := NewLocalDiscovery(log.DefaultLogger)
+	assert.NotNil(t, err)
+
+	t.Setenv(FileEnvName, filepath.Join(t.TempDir(), "registry.yaml"))
+
+	// 所有客户端共享同一个服务发现
+	a, err := NewLocalDiscovery(log.DefaultLogger)
+	assert.Nil(t, err)
+	b, err := NewLocalDiscovery(log.DefaultLogger)
+	assert.Nil(t, err)
+	assert.Same(t, a, b)
+	assert.IsType(t, &FileRegistry{}, a)
+}
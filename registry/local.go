@@ -0,0 +1,57 @@
+package registry
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-kratos/kratos/v2/log"      // nolint
+	"github.com/go-kratos/kratos/v2/registry" // nolint
+)
+
+const (
+	// FileEnvName 本地服务发现文件路径
+	FileEnvName = "REGISTRY_FILE"
+	// EnvName 服务发现方式，NO 不使用服务发现，DNS 使用 DNS 服务发现
+	EnvName = "REGISTRY"
+)
+
+var (
+	localMux       sync.Mutex
+	localDiscovery registry.Discovery
+)
+
+// NewLocalDiscovery 非 Kubernetes 环境下根据环境变量创建服务发现，未配置时返回 nil
+// REGISTRY_FILE=/path/to/registry.yaml 使用文件服务发现；REGISTRY=DNS 使用 DNS 服务发现；
+// 进程内只保留第一次创建成功的服务发现，所有客户端共享，避免每个客户端都创建文件监听；创建失败时下次调用会重新创建
+func NewLocalDiscovery(logger log.Logger) (registry.Discovery, error) {
+	localMux.Lock()
+	defer localMux.Unlock()
+
+	if localDiscovery != nil {
+		return localDiscovery, nil
+	}
+
+	discovery, err := newLocalDiscovery(logger)
+	if err != nil || discovery == nil {
+		return nil, err
+	}
+
+	localDiscovery = discovery
+	return discovery, nil
+}
+
+func newLocalDiscovery(logger log.Logger) (registry.Discovery, error) {
+	if path := os.Getenv(FileEnvName); path != "" {
+		discovery, err := NewFileRegistry(path, logger)
+		if err != nil {
+			return nil, err
+		}
+		return discovery, nil
+	}
+
+	if strings.ToUpper(os.Getenv(EnvName)) == "DNS" {
+		return NewDNSRegistry(logger), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,164 @@
+package registry
+
+import (
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// NamespacesEnvName 允许发现的命名空间列表，多个使用逗号分隔
+	NamespacesEnvName = "REGISTRY_NAMESPACES"
+	// ClusterNameEnvName 当前集群名称，与 kratos-service-metadata 中的 cluster 对应
+	ClusterNameEnvName = "CLUSTER_NAME"
+)
+
+type cluster struct {
+	name      string
+	clientSet kubernetes.Interface
+}
+
+type options struct {
+	namespaces  []string
+	clusterName string
+	clusters    []*cluster
+	resync      time.Duration
+	selector    *Selector
+}
+
+type Option func(o *options)
+
+// WithNamespaces 允许通过 svc.namespace 访问的命名空间，每个命名空间会创建一个 informer
+func WithNamespaces(namespaces ...string) Option {
+	return func(o *options) {
+		o.namespaces = append(o.namespaces, namespaces...)
+	}
+}
+
+// WithClusterName 当前集群名称，存在多个集群时优先返回当前集群的实例
+func WithClusterName(name string) Option {
+	return func(o *options) {
+		o.clusterName = name
+	}
+}
+
+// WithCluster 增加一个远端集群用于服务发现，clientSet 一般通过 kubeconfig 创建
+func WithCluster(name string, clientSet kubernetes.Interface) Option {
+	return func(o *options) {
+		o.clusters = append(o.clusters, &cluster{
+			name:      name,
+			clientSet: clientSet,
+		})
+	}
+}
+
+// WithResync informer 的全量同步周期
+func WithResync(resync time.Duration) Option {
+	return func(o *options) {
+		o.resync = resync
+	}
+}
+
+// WithSelector 只返回匹配的实例，例如: version=v3.*,metadata.zone=sh001
+func WithSelector(selector *Selector) Option {
+	return func(o *options) {
+		o.selector = selector
+	}
+}
+
+func defaultOptions() *options {
+	o := &options{
+		clusterName: os.Getenv(ClusterNameEnvName),
+		resync:      time.Minute * 10,
+	}
+
+	for _, namespace := range strings.Split(os.Getenv(NamespacesEnvName), ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" {
+			continue
+		}
+		o.namespaces = append(o.namespaces, namespace)
+	}
+	return o
+}
+
+type dnsOptions struct {
+	resolver *net.Resolver
+	interval time.Duration
+	ports    map[string]int
+}
+
+type DNSOption func(o *dnsOptions)
+
+// WithDNSResolver 自定义 DNS 解析器
+func WithDNSResolver(resolver *net.Resolver) DNSOption {
+	return func(o *dnsOptions) {
+		o.resolver = resolver
+	}
+}
+
+// WithDNSInterval DNS 记录的轮询间隔
+func WithDNSInterval(interval time.Duration) DNSOption {
+	return func(o *dnsOptions) {
+		o.interval = interval
+	}
+}
+
+// WithDNSPorts 协议及没有 SRV 记录时使用的默认端口，默认 http:8000 grpc:9000
+func WithDNSPorts(ports map[string]int) DNSOption {
+	return func(o *dnsOptions) {
+		o.ports = ports
+	}
+}
+
+func defaultDNSOptions() *dnsOptions {
+	return &dnsOptions{
+		resolver: net.DefaultResolver,
+		interval: time.Second * 10,
+		ports: map[string]int{
+			"http": 8000,
+			"grpc": 9000,
+		},
+	}
+}
+
+type canaryOptions struct {
+	wscKey      string
+	metadataKey string
+	weightEnv   string
+	weight      int
+}
+
+type CanaryOption func(o *canaryOptions)
+
+// WithCanaryWSCKey Route-Wsc-Val 中表示灰度环境的字段，默认 env
+func WithCanaryWSCKey(key string) CanaryOption {
+	return func(o *canaryOptions) {
+		o.wscKey = key
+	}
+}
+
+// WithCanaryMetadataKey 实例元数据中表示所属环境的字段，默认 env
+func WithCanaryMetadataKey(key string) CanaryOption {
+	return func(o *canaryOptions) {
+		o.metadataKey = key
+	}
+}
+
+// WithCanaryWeight 没有灰度标记的请求按 weight 的百分比（0-100）路由到 env 环境的实例，用于灰度放量
+func WithCanaryWeight(env string, weight int) CanaryOption {
+	return func(o *canaryOptions) {
+		o.weightEnv = env
+		o.weight = weight
+	}
+}
+
+func defaultCanaryOptions() *canaryOptions {
+	return &canaryOptions{
+		wscKey:      "env",
+		metadataKey: MetadataKeyEnv,
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-kratos/kratos/v2/log"      // nolint
@@ -80,31 +81,106 @@ const (
 	PodsNameSpaceName         = "NAMESPACE"
 )
 
+// MetadataKeyCluster is the metadata key of the cluster which the instance belongs to
+const MetadataKeyCluster = "cluster"
+
+// podInformer watches the pods of one namespace in one cluster
+type podInformer struct {
+	cluster   string
+	namespace string
+	factory   informers.SharedInformerFactory
+	informer  cache.SharedIndexInformer
+	lister    listerv1.PodLister
+}
+
+func newPodInformer(clusterName string, clientSet kubernetes.Interface, namespace string, resync time.Duration) *podInformer {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientSet, resync, informers.WithNamespace(namespace))
+	return &podInformer{
+		cluster:   clusterName,
+		namespace: namespace,
+		factory:   factory,
+		informer:  factory.Core().V1().Pods().Informer(),
+		lister:    factory.Core().V1().Pods().Lister(),
+	}
+}
+
 // The Registry simply implements service discovery based on Kubernetes
 // It has not been verified in the production environment and is currently for reference only
+//
+// Besides the current namespace, services in the namespaces of the allow-list can be discovered by `svc.namespace`,
+// and the pods of remote clusters are merged into the result, instances of the current cluster are preferred.
 type Registry struct {
-	clientSet       *kubernetes.Clientset
-	informerFactory informers.SharedInformerFactory
-	podInformer     cache.SharedIndexInformer
-	podLister       listerv1.PodLister
+	clientSet   kubernetes.Interface
+	namespace   string
+	namespaces  map[string]struct{}
+	clusterName string
+	selector    *Selector
+	informers   []*podInformer
 
 	stopCh chan struct{}
 	l      *log.Helper
 }
 
 // NewRegistry is used to initialize the Registry
-func NewRegistry(clientSet *kubernetes.Clientset, logger log.Logger) *Registry {
-	informerFactory := informers.NewSharedInformerFactoryWithOptions(clientSet, time.Minute*10, informers.WithNamespace(GetNamespace()))
-	podInformer := informerFactory.Core().V1().Pods().Informer()
-	podLister := informerFactory.Core().V1().Pods().Lister()
-	return &Registry{
-		clientSet:       clientSet,
-		informerFactory: informerFactory,
-		podInformer:     podInformer,
-		podLister:       podLister,
-		stopCh:          make(chan struct{}),
-		l:               log.NewHelper(logger),
+func NewRegistry(clientSet kubernetes.Interface, logger log.Logger, opts ...Option) *Registry {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var (
+		namespace  = GetNamespace()
+		namespaces = map[string]struct{}{namespace: {}}
+		clusters   = append([]*cluster{{name: o.clusterName, clientSet: clientSet}}, o.clusters...)
+	)
+
+	for _, ns := range o.namespaces {
+		namespaces[ns] = struct{}{}
+	}
+
+	s := &Registry{
+		clientSet:   clientSet,
+		namespace:   namespace,
+		namespaces:  namespaces,
+		clusterName: o.clusterName,
+		selector:    o.selector,
+		stopCh:      make(chan struct{}),
+		l:           log.NewHelper(logger),
+	}
+
+	for _, c := range clusters {
+		// 监听所有命名空间时只需要一个 informer
+		if _, ok := namespaces[metav1.NamespaceAll]; ok {
+			s.informers = append(s.informers, newPodInformer(c.name, c.clientSet, metav1.NamespaceAll, o.resync))
+			continue
+		}
+
+		for ns := range namespaces {
+			s.informers = append(s.informers, newPodInformer(c.name, c.clientSet, ns, o.resync))
+		}
+	}
+	return s
+}
+
+// parseName splits `svc.namespace` into service name and namespace,
+// the suffix is treated as namespace only when it is in the allow-list.
+func (s *Registry) parseName(name string) (string, string) {
+	if i := strings.LastIndex(name, "."); i > 0 && i < len(name)-1 {
+		if _, ok := s.namespaces[name[i+1:]]; ok {
+			return name[:i], name[i+1:]
+		}
+	}
+	return name, s.namespace
+}
+
+func (s *Registry) informersOf(namespace string) []*podInformer {
+	out := make([]*podInformer, 0, len(s.informers))
+	for _, informer := range s.informers {
+		if informer.namespace == metav1.NamespaceAll || informer.namespace == namespace {
+			out = append(out, informer)
+		}
 	}
+	return out
 }
 
 // Register is used to register services
@@ -144,10 +220,10 @@ func (s *Registry) Register(ctx context.Context, service *registry.ServiceInstan
 		return err
 	}
 
-	s.l.Infof("current_namespace:%v", GetNamespace())
+	s.l.Infof("current_namespace:%v", s.namespace)
 	if _, err = s.clientSet.
 		CoreV1().
-		Pods(GetNamespace()).
+		Pods(s.namespace).
 		Patch(ctx, GetPodName(), types.StrategicMergePatchType, patchBytes, metav1.PatchOptions{}); err != nil {
 		return err
 	}
@@ -162,77 +238,154 @@ func (s *Registry) Deregister(ctx context.Context, _ *registry.ServiceInstance)
 }
 
 // GetService return the service instances in memory according to the service name.
+// The name can be `svc` or `svc.namespace`.
 func (s *Registry) GetService(_ context.Context, name string) ([]*registry.ServiceInstance, error) {
-	pods, err := s.podLister.List(labels.SelectorFromSet(map[string]string{
-		LabelsKeyServiceName: name,
-	}))
-	if err != nil {
-		return nil, err
-	}
-	ret := make([]*registry.ServiceInstance, 0, len(pods))
-	for _, pod := range pods {
-		if pod.Status.Phase != corev1.PodRunning {
-			continue
+	var (
+		serviceName, namespace = s.parseName(name)
+		selector               = labels.SelectorFromSet(map[string]string{
+			LabelsKeyServiceName: serviceName,
+		})
+		ret []*registry.ServiceInstance
+	)
+
+	for _, informer := range s.informersOf(namespace) {
+		var (
+			pods []*corev1.Pod
+			err  error
+		)
+
+		if namespace == metav1.NamespaceAll {
+			pods, err = informer.lister.List(selector)
+		} else {
+			pods, err = informer.lister.Pods(namespace).List(selector)
 		}
-		instance, err := getServiceInstanceFromPod(pod)
 		if err != nil {
 			return nil, err
 		}
-		ret = append(ret, instance)
+
+		for _, pod := range pods {
+			if pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			instance, err := getServiceInstanceFromPod(pod)
+			if err != nil {
+				return nil, err
+			}
+
+			if instance.Metadata[MetadataKeyCluster] == "" && informer.cluster != "" {
+				instance.Metadata[MetadataKeyCluster] = informer.cluster
+			}
+			ret = append(ret, instance)
+		}
 	}
-	return ret, nil
+	return s.preferLocalCluster(s.selector.Filter(ret)), nil
+}
+
+// preferLocalCluster returns the instances of the current cluster if there are any,
+// otherwise the instances of the other clusters are returned as fallback.
+func (s *Registry) preferLocalCluster(instances []*registry.ServiceInstance) []*registry.ServiceInstance {
+	if s.clusterName == "" {
+		return instances
+	}
+
+	local := make([]*registry.ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Metadata[MetadataKeyCluster] == s.clusterName {
+			local = append(local, instance)
+		}
+	}
+
+	if len(local) > 0 {
+		return local
+	}
+	return instances
 }
 
-func (s *Registry) sendLatestInstances(ctx context.Context, name string, announcement chan []*registry.ServiceInstance) {
+func (s *Registry) sendLatestInstances(ctx context.Context, name string, iter *Iterator) {
 	instances, err := s.GetService(ctx, name)
 	if err != nil {
-		panic(err)
+		s.l.Errorf("get service instances[%v] err:%v", name, err)
+		iter.update(nil, err)
+		return
 	}
 	str, _ := json.Marshal(instances)
 	s.l.Infof("获取到的实例[%v]:%v", name, string(str))
-	announcement <- instances
+	iter.update(instances, nil)
 }
 
 // Watch creates a watcher according to the service name.
+// The informer callbacks never block on the watcher: each event replaces the
+// pending snapshot, so a slow consumer only ever sees the latest instances.
 func (s *Registry) Watch(ctx context.Context, name string) (registry.Watcher, error) {
-	stopCh := make(chan struct{}, 1)
-	announcement := make(chan []*registry.ServiceInstance, 1)
-	_, err := s.podInformer.AddEventHandler(cache.FilteringResourceEventHandler{
-		FilterFunc: func(obj interface{}) bool {
-			select {
-			case <-stopCh:
-				return false
-			case <-s.stopCh:
-				return false
-			default:
-				pod := obj.(*corev1.Pod)
-				val := pod.GetLabels()[LabelsKeyServiceName]
-				return val == name
+	var (
+		iter                   = NewIterator(ctx, s.stopCh, s.l)
+		serviceName, namespace = s.parseName(name)
+		informers              = s.informersOf(namespace)
+		registrations          = make([]cache.ResourceEventHandlerRegistration, 0, len(informers))
+	)
+
+	iter.onStop = func() {
+		for i, registration := range registrations {
+			if err := informers[i].informer.RemoveEventHandler(registration); err != nil {
+				s.l.Errorf("remove event handler[%v] err:%v", name, err)
 			}
-		},
-		Handler: cache.ResourceEventHandlerFuncs{
-			AddFunc: func(obj interface{}) {
-				s.sendLatestInstances(ctx, name, announcement)
-			},
-			UpdateFunc: func(oldObj, newObj interface{}) {
-				s.sendLatestInstances(ctx, name, announcement)
+		}
+	}
+
+	for _, informer := range informers {
+		registration, err := informer.informer.AddEventHandler(cache.FilteringResourceEventHandler{
+			FilterFunc: func(obj interface{}) bool {
+				if iter.stopped() {
+					return false
+				}
+
+				pod, ok := podFromObject(obj)
+				if !ok {
+					return false
+				}
+
+				if namespace != metav1.NamespaceAll && pod.Namespace != namespace {
+					return false
+				}
+				return pod.GetLabels()[LabelsKeyServiceName] == serviceName
 			},
-			DeleteFunc: func(obj interface{}) {
-				s.sendLatestInstances(ctx, name, announcement)
+			Handler: cache.ResourceEventHandlerFuncs{
+				AddFunc: func(obj interface{}) {
+					s.sendLatestInstances(ctx, name, iter)
+				},
+				UpdateFunc: func(oldObj, newObj interface{}) {
+					s.sendLatestInstances(ctx, name, iter)
+				},
+				DeleteFunc: func(obj interface{}) {
+					s.sendLatestInstances(ctx, name, iter)
+				},
 			},
-		},
-	})
-	if err != nil {
-		return nil, err
+		})
+		if err != nil {
+			_ = iter.Stop()
+			return nil, err
+		}
+		registrations = append(registrations, registration)
 	}
-	return NewIterator(announcement, stopCh, s.l), nil
+
+	// ctx 结束或者 Registry 关闭时移除 informer 的回调
+	go iter.stopOnDone()
+
+	// 先推送一次当前快照，避免没有pod事件时 Next 一直阻塞
+	s.sendLatestInstances(ctx, name, iter)
+	return iter, nil
 }
 
 // Start is used to start the Registry
 // It is non-blocking
 func (s *Registry) Start() {
-	s.informerFactory.Start(s.stopCh)
-	if !cache.WaitForCacheSync(s.stopCh, s.podInformer.HasSynced) {
+	hasSynced := make([]cache.InformerSynced, 0, len(s.informers))
+	for _, informer := range s.informers {
+		informer.factory.Start(s.stopCh)
+		hasSynced = append(hasSynced, informer.informer.HasSynced)
+	}
+
+	if !cache.WaitForCacheSync(s.stopCh, hasSynced...) {
 		return
 	}
 }
@@ -283,41 +436,105 @@ func (m protocolMap) GetProtocol(port int32) string {
 
 // //////////// Iterator ////////////
 
-// Iterator performs the conversion from channel to iterator
-// It reads the latest changes from the `chan []*registry.ServiceInstance`
+// Iterator performs the conversion from informer events to iterator
+// It only keeps the latest snapshot of the service instances, so the producer never blocks
 // And the outside can sense the closure of Iterator through stopCh
 type Iterator struct {
-	ch     chan []*registry.ServiceInstance
-	stopCh chan struct{}
-	l      *log.Helper
+	mu        sync.Mutex
+	instances []*registry.ServiceInstance
+	err       error
+
+	ctx      context.Context
+	notify   chan struct{}
+	stopCh   chan struct{}
+	parentCh <-chan struct{}
+	stopOnce sync.Once
+	onStop   func()
+	l        *log.Helper
 }
 
 // NewIterator is used to initialize Iterator
-func NewIterator(channel chan []*registry.ServiceInstance, stopCh chan struct{}, l *log.Helper) *Iterator {
+// The iterator is closed when ctx is done, parentCh is closed or Stop is called
+func NewIterator(ctx context.Context, parentCh <-chan struct{}, l *log.Helper) *Iterator {
 	return &Iterator{
-		ch:     channel,
-		stopCh: stopCh,
-		l:      l,
+		ctx:      ctx,
+		notify:   make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+		parentCh: parentCh,
+		l:        l,
+	}
+}
+
+// update replaces the pending snapshot and wakes up Next without blocking
+func (iter *Iterator) update(instances []*registry.ServiceInstance, err error) {
+	iter.mu.Lock()
+	iter.instances = instances
+	iter.err = err
+	iter.mu.Unlock()
+
+	select {
+	case iter.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (iter *Iterator) stopped() bool {
+	select {
+	case <-iter.stopCh:
+		return true
+	case <-iter.parentCh:
+		return true
+	case <-iter.ctx.Done():
+		return true
+	default:
+		return false
 	}
 }
 
+// stopOnDone calls Stop when ctx is done or parentCh is closed, so that onStop always runs
+func (iter *Iterator) stopOnDone() {
+	select {
+	case <-iter.ctx.Done():
+	case <-iter.parentCh:
+	case <-iter.stopCh:
+		return
+	}
+	_ = iter.Stop()
+}
+
 // Next will block until ServiceInstance changes
+// If the latest change failed, the error is returned instead of the instances
 func (iter *Iterator) Next() ([]*registry.ServiceInstance, error) {
 	select {
-	case instances := <-iter.ch:
-		return instances, nil
+	case <-iter.notify:
 	case <-iter.stopCh:
+		// ctx 结束时 stopOnDone 也会关闭 stopCh
+		if err := iter.ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrIteratorClosed
+	case <-iter.parentCh:
 		return nil, ErrIteratorClosed
+	case <-iter.ctx.Done():
+		return nil, iter.ctx.Err()
+	}
+
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	if iter.err != nil {
+		return nil, iter.err
 	}
+	return iter.instances, nil
 }
 
 // Stop is used to close the iterator
 func (iter *Iterator) Stop() error {
-	select {
-	case <-iter.stopCh:
-	default:
+	iter.stopOnce.Do(func() {
 		close(iter.stopCh)
-	}
+		if iter.onStop != nil {
+			iter.onStop()
+		}
+	})
 	return nil
 }
 
@@ -331,6 +548,17 @@ func unmarshal(data string, in interface{}) error {
 	return jsoniter.UnmarshalFromString(data, in)
 }
 
+func podFromObject(obj interface{}) (*corev1.Pod, bool) {
+	switch v := obj.(type) {
+	case *corev1.Pod:
+		return v, true
+	case cache.DeletedFinalStateUnknown:
+		pod, ok := v.Obj.(*corev1.Pod)
+		return pod, ok
+	}
+	return nil, false
+}
+
 func isEmptyObjectString(s string) bool {
 	switch s {
 	case "", "{}", "null", "nil", "[]":
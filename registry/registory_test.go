@@ -0,0 +1,283 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "default"
+
+func newTestPod(name, app, version, ip string, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				LabelsKeyServiceID:      name,
+				LabelsKeyServiceName:    app,
+				LabelsKeyServiceVersion: version,
+			},
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: app,
+					Ports: []corev1.ContainerPort{
+						{Name: "http-server", ContainerPort: 8000},
+						{ContainerPort: 9000},
+					},
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: ip,
+		},
+	}
+}
+
+func newTestRegistry(t *testing.T, opts ...Option) (*Registry, *fake.Clientset) {
+	currentNamespace = testNamespace
+	t.Cleanup(func() { currentNamespace = LoadNamespace() })
+
+	clientSet := fake.NewSimpleClientset()
+	r := NewRegistry(clientSet, log.DefaultLogger, opts...)
+	r.Start()
+	t.Cleanup(r.Close)
+	return r, clientSet
+}
+
+func createPod(t *testing.T, clientSet *fake.Clientset, pod *corev1.Pod) {
+	_, err := clientSet.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	assert.Nil(t, err)
+}
+
+// nextUntil reads the watcher until the instances match, the intermediate snapshots may be coalesced
+func nextUntil(t *testing.T, w registry.Watcher, match func([]*registry.ServiceInstance, error) bool) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			instances, err := w.Next()
+			if match(instances, err) || errors.Is(err, ErrIteratorClosed) {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		_ = w.Stop()
+		t.Fatal("watcher did not emit the expected instances")
+	}
+}
+
+func TestGetService(t *testing.T) {
+	r, clientSet := newTestRegistry(t)
+
+	pending := newTestPod("user-2", "user", "v1.0.0", "10.0.0.2", nil)
+	pending.Status.Phase = corev1.PodPending
+	createPod(t, clientSet, newTestPod("user-1", "user", "v1.0.0", "10.0.0.1", map[string]string{
+		AnnotationsKeyMetadata:    `{"zone": "sh001"}`,
+		AnnotationsKeyProtocolMap: `{"9000": "grpc"}`,
+	}))
+	createPod(t, clientSet, pending)
+	createPod(t, clientSet, newTestPod("order-1", "order", "v1.0.0", "10.0.0.3", nil))
+
+	assert.Eventually(t, func() bool {
+		instances, err := r.GetService(context.Background(), "user")
+		return err == nil && len(instances) == 1
+	}, time.Second*5, time.Millisecond*10)
+
+	instances, err := r.GetService(context.Background(), "user")
+	assert.Nil(t, err)
+	assert.Equal(t, &registry.ServiceInstance{
+		ID:        "user-1",
+		Name:      "user",
+		Version:   "v1.0.0",
+		Metadata:  map[string]string{"zone": "sh001"},
+		Endpoints: []string{"http://10.0.0.1:8000", "grpc://10.0.0.1:9000"},
+	}, instances[0])
+}
+
+func TestGetServiceMalformedAnnotation(t *testing.T) {
+	r, clientSet := newTestRegistry(t)
+	createPod(t, clientSet, newTestPod("user-1", "user", "v1.0.0", "10.0.0.1", map[string]string{
+		AnnotationsKeyMetadata: `{"zone":`,
+	}))
+
+	assert.Eventually(t, func() bool {
+		_, err := r.GetService(context.Background(), "user")
+		return err != nil
+	}, time.Second*5, time.Millisecond*10)
+
+	_, err := r.GetService(context.Background(), "user")
+	var target *ErrorHandleResource
+	assert.True(t, errors.As(err, &target))
+	assert.Equal(t, testNamespace, target.Namespace)
+	assert.Equal(t, "user-1", target.Name)
+
+	// 错误通过 Next 返回，而不是 panic
+	w, err := r.Watch(context.Background(), "user")
+	assert.Nil(t, err)
+	defer w.Stop()
+	_, err = w.Next()
+	assert.True(t, errors.As(err, &target))
+}
+
+func TestWatch(t *testing.T) {
+	r, clientSet := newTestRegistry(t)
+	ctx := context.Background()
+
+	w, err := r.Watch(ctx, "user")
+	assert.Nil(t, err)
+	defer w.Stop()
+
+	// 初始快照
+	instances, err := w.Next()
+	assert.Nil(t, err)
+	assert.Len(t, instances, 0)
+
+	// create
+	createPod(t, clientSet, newTestPod("user-1", "user", "v1.0.0", "10.0.0.1", nil))
+	nextUntil(t, w, func(instances []*registry.ServiceInstance, err error) bool {
+		return err == nil && len(instances) == 1 && instances[0].Version == "v1.0.0"
+	})
+
+	// update
+	pod := newTestPod("user-1", "user", "v2.0.0", "10.0.0.1", nil)
+	_, err = clientSet.CoreV1().Pods(testNamespace).Update(ctx, pod, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	nextUntil(t, w, func(instances []*registry.ServiceInstance, err error) bool {
+		return err == nil && len(instances) == 1 && instances[0].Version == "v2.0.0"
+	})
+
+	// 其他服务的变更不影响
+	createPod(t, clientSet, newTestPod("order-1", "order", "v1.0.0", "10.0.0.2", nil))
+
+	// delete
+	err = clientSet.CoreV1().Pods(testNamespace).Delete(ctx, "user-1", metav1.DeleteOptions{})
+	assert.Nil(t, err)
+	nextUntil(t, w, func(instances []*registry.ServiceInstance, err error) bool {
+		return err == nil && len(instances) == 0
+	})
+
+	// stop
+	assert.Nil(t, w.Stop())
+	_, err = w.Next()
+	assert.Equal(t, ErrIteratorClosed, err)
+}
+
+func TestWatchStopOnDone(t *testing.T) {
+	r, _ := newTestRegistry(t)
+
+	// ctx 结束时移除 informer 的回调
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := r.Watch(ctx, "user")
+	assert.Nil(t, err)
+	_, err = w.Next()
+	assert.Nil(t, err)
+	cancel()
+
+	select {
+	case <-w.(*Iterator).stopCh:
+	case <-time.After(time.Second * 5):
+		t.Fatal("watcher was not stopped when ctx is done")
+	}
+	_, err = w.Next()
+	assert.Equal(t, context.Canceled, err)
+
+	// Registry 关闭时同样移除
+	w, err = r.Watch(context.Background(), "user")
+	assert.Nil(t, err)
+	r.Close()
+
+	select {
+	case <-w.(*Iterator).stopCh:
+	case <-time.After(time.Second * 5):
+		t.Fatal("watcher was not stopped when the registry is closed")
+	}
+}
+
+func TestWatchSlowConsumer(t *testing.T) {
+	r, clientSet := newTestRegistry(t)
+	ctx := context.Background()
+
+	slow, err := r.Watch(ctx, "user")
+	assert.Nil(t, err)
+	defer slow.Stop()
+
+	w, err := r.Watch(ctx, "user")
+	assert.Nil(t, err)
+	defer w.Stop()
+
+	// slow 不读取任何快照，也不能阻塞其他 watcher
+	for _, name := range []string{"user-1", "user-2", "user-3"} {
+		createPod(t, clientSet, newTestPod(name, "user", "v1.0.0", "10.0.0.1", nil))
+	}
+	nextUntil(t, w, func(instances []*registry.ServiceInstance, err error) bool {
+		return err == nil && len(instances) == 3
+	})
+
+	// slow 读到的是合并后的最新快照
+	nextUntil(t, slow, func(instances []*registry.ServiceInstance, err error) bool {
+		return err == nil && len(instances) == 3
+	})
+}
+
+func TestRegister(t *testing.T) {
+	t.Setenv("HOSTNAME", "user-1")
+	r, clientSet := newTestRegistry(t)
+	createPod(t, clientSet, newTestPod("user-1", "", "", "10.0.0.1", nil))
+
+	err := r.Register(context.Background(), &registry.ServiceInstance{
+		ID:        "id-1",
+		Name:      "user",
+		Version:   "v1.0.0",
+		Metadata:  map[string]string{"zone": "sh001"},
+		Endpoints: []string{"http://10.0.0.1:8000", "grpc://10.0.0.1:9000"},
+	})
+	assert.Nil(t, err)
+
+	pod, err := clientSet.CoreV1().Pods(testNamespace).Get(context.Background(), "user-1", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "id-1", pod.Labels[LabelsKeyServiceID])
+	assert.Equal(t, "user", pod.Labels[LabelsKeyServiceName])
+	assert.Equal(t, "v1.0.0", pod.Labels[LabelsKeyServiceVersion])
+	assert.JSONEq(t, `{"zone":"sh001"}`, pod.Annotations[AnnotationsKeyMetadata])
+	assert.JSONEq(t, `{"8000":"http","9000":"grpc"}`, pod.Annotations[AnnotationsKeyProtocolMap])
+}
+
+func TestGetServiceWithNamespaceAndSelector(t *testing.T) {
+	r, clientSet := newTestRegistry(t,
+		WithNamespaces("other"),
+		WithSelector(MustParseSelector("version=v3.*")),
+	)
+
+	other := newTestPod("order-1", "order", "v3.5.0", "10.0.0.1", nil)
+	other.Namespace = "other"
+	createPod(t, clientSet, other)
+	createPod(t, clientSet, newTestPod("order-2", "order", "v3.1.0", "10.0.0.2", nil))
+	createPod(t, clientSet, newTestPod("order-3", "order", "v2.0.0", "10.0.0.3", nil))
+
+	assert.Eventually(t, func() bool {
+		instances, err := r.GetService(context.Background(), "order.other")
+		return err == nil && len(instances) == 1 && instances[0].ID == "order-1"
+	}, time.Second*5, time.Millisecond*10)
+
+	assert.Eventually(t, func() bool {
+		instances, err := r.GetService(context.Background(), "order")
+		return err == nil && len(instances) == 1 && instances[0].ID == "order-2"
+	}, time.Second*5, time.Millisecond*10)
+}
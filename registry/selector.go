@@ -0,0 +1,256 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/registry" // nolint
+	"github.com/go-kratos/kratos/v2/selector"
+)
+
+const (
+	selectorKeyVersion        = "version"
+	selectorKeyMetadataPrefix = "metadata."
+)
+
+// Selector filters the instances by version and metadata
+// Example: version=v3.*,metadata.zone=sh001,metadata.env!=gray,metadata.zone in (sh001,sh002),version notin (v1.*)
+// The value supports glob patterns, all requirements must be matched.
+type Selector struct {
+	requirements []*requirement
+}
+
+type requirement struct {
+	key      string
+	patterns []string
+	not      bool
+}
+
+// ParseSelector is used to parse the selector expression
+func ParseSelector(in string) (*Selector, error) {
+	terms, err := splitTerms(in)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Selector{}
+	for _, term := range terms {
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+
+		if req.key != selectorKeyVersion && !strings.HasPrefix(req.key, selectorKeyMetadataPrefix) {
+			return nil, fmt.Errorf("invalid selector key:%s", req.key)
+		}
+
+		for _, pattern := range req.patterns {
+			if _, err = path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid selector pattern:%s", pattern)
+			}
+		}
+		s.requirements = append(s.requirements, req)
+	}
+	return s, nil
+}
+
+// splitTerms splits the expression by the commas outside the parentheses
+func splitTerms(in string) ([]string, error) {
+	var (
+		out   []string
+		depth int
+		start int
+	)
+
+	for i, c := range in {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, in[start:i])
+				start = i + 1
+			}
+		}
+
+		if depth < 0 || depth > 1 {
+			return nil, fmt.Errorf("invalid selector:%s", in)
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("invalid selector:%s", in)
+	}
+	out = append(out, in[start:])
+
+	terms := make([]string, 0, len(out))
+	for _, term := range out {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms, nil
+}
+
+// parseRequirement parses the term of key=pattern, key!=pattern, key in (a,b) or key notin (a,b)
+func parseRequirement(term string) (*requirement, error) {
+	if i := strings.IndexByte(term, '('); i >= 0 {
+		fields := strings.Fields(term[:i])
+		if len(fields) != 2 || (fields[1] != "in" && fields[1] != "notin") || !strings.HasSuffix(term, ")") {
+			return nil, fmt.Errorf("invalid selector term:%s", term)
+		}
+
+		req := &requirement{
+			key: fields[0],
+			not: fields[1] == "notin",
+		}
+		for _, pattern := range strings.Split(term[i+1:len(term)-1], ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				req.patterns = append(req.patterns, pattern)
+			}
+		}
+
+		if len(req.patterns) == 0 {
+			return nil, fmt.Errorf("invalid selector term:%s", term)
+		}
+		return req, nil
+	}
+
+	var (
+		req = &requirement{}
+		sep = "="
+	)
+
+	if strings.Contains(term, "!=") {
+		req.not = true
+		sep = "!="
+	}
+
+	splits := strings.SplitN(term, sep, 2)
+	if len(splits) != 2 {
+		return nil, fmt.Errorf("invalid selector term:%s", term)
+	}
+
+	req.key = strings.TrimSpace(splits[0])
+	req.patterns = []string{strings.TrimSpace(splits[1])}
+	return req, nil
+}
+
+// MustParseSelector is like ParseSelector but panics if the expression is invalid
+func MustParseSelector(in string) *Selector {
+	s, err := ParseSelector(in)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *Selector) match(version string, metadata map[string]string) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, req := range s.requirements {
+		value := version
+		if req.key != selectorKeyVersion {
+			value = metadata[strings.TrimPrefix(req.key, selectorKeyMetadataPrefix)]
+		}
+
+		if req.match(value) == req.not {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *requirement) match(value string) bool {
+	for _, pattern := range r.patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the instance matches the selector
+func (s *Selector) Match(instance *registry.ServiceInstance) bool {
+	return s.match(instance.Version, instance.Metadata)
+}
+
+// Filter returns the instances which match the selector
+func (s *Selector) Filter(instances []*registry.ServiceInstance) []*registry.ServiceInstance {
+	if s == nil || len(s.requirements) == 0 {
+		return instances
+	}
+
+	out := make([]*registry.ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if s.Match(instance) {
+			out = append(out, instance)
+		}
+	}
+	return out
+}
+
+// NodeFilter returns the client side node filter of the selector
+func (s *Selector) NodeFilter() selector.NodeFilter {
+	return func(_ context.Context, nodes []selector.Node) []selector.Node {
+		out := make([]selector.Node, 0, len(nodes))
+		for _, node := range nodes {
+			if s.match(node.Version(), node.Metadata()) {
+				out = append(out, node)
+			}
+		}
+		return out
+	}
+}
+
+// NewSelectorDiscovery wraps the discovery, only the instances which match the selector are returned
+func NewSelectorDiscovery(discovery registry.Discovery, s *Selector) registry.Discovery {
+	return &selectorDiscovery{
+		discovery: discovery,
+		selector:  s,
+	}
+}
+
+type selectorDiscovery struct {
+	discovery registry.Discovery
+	selector  *Selector
+}
+
+func (d *selectorDiscovery) GetService(ctx context.Context, name string) ([]*registry.ServiceInstance, error) {
+	instances, err := d.discovery.GetService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return d.selector.Filter(instances), nil
+}
+
+func (d *selectorDiscovery) Watch(ctx context.Context, name string) (registry.Watcher, error) {
+	watcher, err := d.discovery.Watch(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &selectorWatcher{
+		Watcher:  watcher,
+		selector: d.selector,
+	}, nil
+}
+
+type selectorWatcher struct {
+	registry.Watcher
+	selector *Selector
+}
+
+func (w *selectorWatcher) Next() ([]*registry.ServiceInstance, error) {
+	instances, err := w.Watcher.Next()
+	if err != nil {
+		return nil, err
+	}
+	return w.selector.Filter(instances), nil
+}
@@ -0,0 +1,140 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/registry"
+	"github.com/go-kratos/kratos/v2/selector"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInstance(id, version string, metadata map[string]string) *registry.ServiceInstance {
+	return &registry.ServiceInstance{
+		ID:        id,
+		Name:      "user",
+		Version:   version,
+		Metadata:  metadata,
+		Endpoints: []string{"grpc://" + id + ":9000"},
+	}
+}
+
+func instanceIDs(instances []*registry.ServiceInstance) []string {
+	out := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		out = append(out, instance.ID)
+	}
+	return out
+}
+
+func nodeIDs(nodes []selector.Node) []string {
+	out := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		out = append(out, node.Address())
+	}
+	return out
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		in  string
+		err bool
+	}{
+		{in: ""},
+		{in: "version=v3.*"},
+		{in: " version = v3.* , metadata.zone=sh001 "},
+		{in: "metadata.env!=gray"},
+		{in: "metadata.zone in (sh001, sh002),version notin (v1.*,v2.*)"},
+		{in: "version", err: true},
+		{in: "zone=sh001", err: true},
+		{in: "version=[", err: true},
+		{in: "metadata.zone in ()", err: true},
+		{in: "metadata.zone in (sh001", err: true},
+		{in: "metadata.zone has (sh001)", err: true},
+		{in: "metadata.zone in ((sh001))", err: true},
+		{in: "metadata.zone in sh001)", err: true},
+	}
+	for _, test := range tests {
+		_, err := ParseSelector(test.in)
+		assert.Equal(t, test.err, err != nil, test.in)
+	}
+
+	assert.Panics(t, func() { MustParseSelector("zone=sh001") })
+}
+
+func TestSelector(t *testing.T) {
+	instances := []*registry.ServiceInstance{
+		newTestInstance("a", "v3.5.0", map[string]string{"zone": "sh001"}),
+		newTestInstance("b", "v3.6.0", map[string]string{"zone": "sh002", "env": "gray"}),
+		newTestInstance("c", "v2.0.0", map[string]string{"zone": "bj001"}),
+		newTestInstance("d", "v1.0.0", nil),
+	}
+
+	tests := []struct {
+		in  string
+		ids []string
+	}{
+		{in: "", ids: []string{"a", "b", "c", "d"}},
+		{in: "version=v3.*", ids: []string{"a", "b"}},
+		{in: "version=v3.*,metadata.zone=sh001", ids: []string{"a"}},
+		{in: "metadata.env!=gray", ids: []string{"a", "c", "d"}},
+		{in: "metadata.zone in (sh001,bj*)", ids: []string{"a", "c"}},
+		{in: "version notin (v1.*,v2.*)", ids: []string{"a", "b"}},
+		{in: "metadata.zone notin (sh*),version!=v1.*", ids: []string{"c"}},
+		{in: "metadata.zone=", ids: []string{"d"}},
+	}
+	for _, test := range tests {
+		s := MustParseSelector(test.in)
+		assert.Equal(t, test.ids, instanceIDs(s.Filter(instances)), test.in)
+
+		nodes := make([]selector.Node, 0, len(instances))
+		for _, instance := range instances {
+			nodes = append(nodes, selector.NewNode("grpc", instance.ID, instance))
+		}
+		assert.Equal(t, test.ids, nodeIDs(s.NodeFilter()(context.Background(), nodes)), test.in)
+	}
+}
+
+type testDiscovery struct {
+	instances []*registry.ServiceInstance
+}
+
+func (d *testDiscovery) GetService(context.Context, string) ([]*registry.ServiceInstance, error) {
+	return d.instances, nil
+}
+
+func (d *testDiscovery) Watch(context.Context, string) (registry.Watcher, error) {
+	return &testWatcher{instances: d.instances}, nil
+}
+
+type testWatcher struct {
+	instances []*registry.ServiceInstance
+}
+
+func (w *testWatcher) Next() ([]*registry.ServiceInstance, error) {
+	return w.instances, nil
+}
+
+func (w *testWatcher) Stop() error {
+	return nil
+}
+
+func TestNewSelectorDiscovery(t *testing.T) {
+	discovery := NewSelectorDiscovery(&testDiscovery{
+		instances: []*registry.ServiceInstance{
+			newTestInstance("a", "v3.5.0", nil),
+			newTestInstance("b", "v2.0.0", nil),
+		},
+	}, MustParseSelector("version=v3.*"))
+
+	instances, err := discovery.GetService(context.Background(), "user")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, instanceIDs(instances))
+
+	w, err := discovery.Watch(context.Background(), "user")
+	assert.Nil(t, err)
+	instances, err = w.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, instanceIDs(instances))
+	assert.Nil(t, w.Stop())
+}